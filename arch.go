@@ -0,0 +1,71 @@
+package repos
+
+// archCompat: The arches that can be installed on an arch, best first.
+var archCompat = map[string][]string{
+	"x86_64":  {"x86_64", "noarch", "i686", "i586", "i486", "i386"},
+	"i686":    {"i686", "noarch", "i586", "i486", "i386"},
+	"aarch64": {"aarch64", "noarch"},
+	"armv7hl": {"armv7hl", "noarch", "armv7l", "armv6l", "armv5tel"},
+	"ppc64le": {"ppc64le", "noarch"},
+	"ppc64":   {"ppc64", "noarch", "ppc"},
+	"s390x":   {"s390x", "noarch", "s390"},
+}
+
+// CompatArches: The arches of packages that can be installed on the
+// basearch, best first. Eg. x86_64 is x86_64, noarch, i686, etc.
+// Unknown arches are just themselves and noarch.
+func CompatArches(basearch string) []string {
+	arches, ok := archCompat[basearch]
+	if !ok {
+		return []string{basearch, "noarch"}
+	}
+	return append([]string(nil), arches...)
+}
+
+func archRanks(arch string) map[string]int {
+	arches := CompatArches(arch)
+
+	ret := make(map[string]int, len(arches))
+	for i, a := range arches {
+		ret[a] = i
+	}
+	return ret
+}
+
+// Arches: The packages with one of the arches, like repoquery --arch.
+func (pkgs *Pkgs) Arches(arches ...string) *Pkgs {
+	ok := make(map[string]bool, len(arches))
+	for _, a := range arches {
+		ok[a] = true
+	}
+
+	ret := &Pkgs{Repo: pkgs.Repo}
+	for _, p := range pkgs.Pkgs {
+		if ok[p.arch] {
+			ret.Pkgs = append(ret.Pkgs, p)
+		}
+	}
+
+	return ret
+}
+
+// CompatArch: The packages that can be installed on the basearch, see
+// CompatArches.
+func (pkgs *Pkgs) CompatArch(basearch string) *Pkgs {
+	return pkgs.Arches(CompatArches(basearch)...)
+}
+
+// Filter: Like the repoquery --arch, --forcearch and --latest-limit options,
+// in that order. Each is skipped when it's empty or 0.
+func (pkgs *Pkgs) Filter(arches []string, basearch string, latest int) *Pkgs {
+	if len(arches) > 0 {
+		pkgs = pkgs.Arches(arches...)
+	}
+	if basearch != "" {
+		pkgs = pkgs.CompatArch(basearch)
+	}
+	if latest != 0 {
+		pkgs = pkgs.Latest(latest)
+	}
+	return pkgs
+}
@@ -0,0 +1,334 @@
+package repos
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotCached: Returned, wrapped, for anything an Offline Cache doesn't have.
+var ErrNotCached = errors.New("not in cache")
+
+// Cache: Keeps downloaded metadata in Dir, in a directory for each repo id.
+// Metalinks, mirrorlists and repomd.xml are kept by URL and used again until
+// they are older than Expire, a negative Expire means they never are. After
+// that, if the Fetcher is a ConditionalFetcher, they are only downloaded again
+// if they've changed, so a 0 Expire is cheap to poll with. Data
+// from repomd.xml is kept by its checksums, so is used again for as long as
+// repomd.xml doesn't change, and removed once a new repomd.xml doesn't have
+// it. Offline uses whatever is in the cache, however old, and never touches
+// the network.
+type Cache struct {
+	Dir     string
+	Expire  time.Duration
+	Offline bool
+}
+
+// DataFetcher: A Fetcher that can also use the checksums of the data, from
+// repomd.xml, Eg. to cache it.
+type DataFetcher interface {
+	Fetcher
+	FetchData(ctx context.Context, url string, d Data) (*Response, error)
+}
+
+type cacheFetcher struct {
+	c   *Cache
+	dir string
+	f   Fetcher
+}
+
+// cacheCleaner: A Fetcher that keeps what it downloads, and can be told when
+// some of that isn't any use.
+type cacheCleaner interface {
+	// dropURL: The download of the URL was bad, so don't use it again.
+	dropURL(url string)
+	// keepData: Remove any data that isn't one of ds.
+	keepData(ds []Data)
+}
+
+// cacheID: The repo id as a single directory name.
+func cacheID(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+// Fetcher: A Fetcher for the repo id, using f (nil for DefaultFetcher) for
+// anything that isn't in the cache. Local files aren't cached.
+func (c *Cache) Fetcher(id string, f Fetcher) Fetcher {
+	return &cacheFetcher{c: c, dir: filepath.Join(c.Dir, cacheID(id)), f: f}
+}
+
+// local: Is the URL a local file, which isn't worth caching.
+func local(url string) bool {
+	scheme := urlScheme(url)
+	return scheme == "" || scheme == "file"
+}
+
+func (cf *cacheFetcher) Fetch(ctx context.Context, url string) (*Response, error) {
+	if local(url) {
+		return fetcher(cf.f).Fetch(ctx, url)
+	}
+
+	fname := cf.urlFile(url)
+	fi, err := os.Stat(fname)
+	if err != nil {
+		return cf.fetch(ctx, url, fname, nil)
+	}
+	if cf.c.Offline || cf.c.Expire < 0 ||
+		time.Since(fi.ModTime()) < cf.c.Expire {
+		return cacheOpen(fname)
+	}
+
+	// Expired, but if it hasn't changed it can be used for another Expire.
+	cfr, ok := fetcher(cf.f).(ConditionalFetcher)
+	etag, modTime := readValidators(fname)
+	if !ok || (etag == "" && modTime.IsZero()) {
+		return cf.fetch(ctx, url, fname, nil)
+	}
+	resp, err := cfr.FetchIf(ctx, url, etag, modTime)
+	if err != nil {
+		return nil, err
+	}
+	if resp.NotModified {
+		now := time.Now()
+		os.Chtimes(fname, now, now)
+		return cacheOpen(fname)
+	}
+	return cf.save(resp, fname, nil)
+}
+
+// urlFile: Where anything not kept by checksum is kept, by URL.
+func (cf *cacheFetcher) urlFile(url string) string {
+	fname := fmt.Sprintf("%x-%s", sha256.Sum256([]byte(url)),
+		cacheID(path.Base(url)))
+	return filepath.Join(cf.dir, fname)
+}
+
+func (cf *cacheFetcher) dropURL(url string) {
+	if local(url) {
+		return
+	}
+	fname := cf.urlFile(url)
+	os.Remove(fname)
+	os.Remove(fname + ".validators")
+}
+
+func (cf *cacheFetcher) FetchData(ctx context.Context, url string,
+	d Data) (*Response, error) {
+	if local(url) || len(d.Chks) == 0 {
+		return cf.Fetch(ctx, url)
+	}
+
+	cw, err := newChksWriter(d.Chks)
+	if err != nil {
+		return nil, err
+	}
+
+	fname := cf.dataFile(d)
+	if _, err := os.Stat(fname); err == nil {
+		resp, err := cacheOpen(fname)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = &cachedBody{rc: resp.Body, fname: fname, cw: cw}
+		return resp, nil
+	}
+
+	return cf.fetch(ctx, url, fname, cw)
+}
+
+// dataFile: Where the data is kept, by its first checksum. The checksums are
+// from repomd.xml, so can't be trusted to be a file name.
+func (cf *cacheFetcher) dataFile(d Data) string {
+	chk := d.Chks[0]
+	fname := cacheID(chk.Kind) + "-" + cacheID(chk.Data) + "-" +
+		cacheID(path.Base(d.Path))
+	return filepath.Join(cf.dir, "data", fname)
+}
+
+func (cf *cacheFetcher) keepData(ds []Data) {
+	keep := make(map[string]bool, len(ds))
+	for _, d := range ds {
+		if len(d.Chks) > 0 {
+			keep[cf.dataFile(d)] = true
+		}
+	}
+
+	dir := filepath.Join(cf.dir, "data")
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, fi := range fis {
+		// Downloads in progress are still temporary files.
+		fname := filepath.Join(dir, fi.Name())
+		if !keep[fname] && !strings.HasPrefix(fi.Name(), ".tmp-") {
+			os.Remove(fname)
+		}
+	}
+}
+
+// cachedBody: Removes fname on Close if it was all read but doesn't match
+// the checksums, so the next mirror downloads it again.
+type cachedBody struct {
+	rc    io.ReadCloser
+	fname string
+	cw    *chksWriter
+	err   error
+	eof   bool
+}
+
+func (cb *cachedBody) Read(p []byte) (int, error) {
+	n, err := cb.rc.Read(p)
+	cb.cw.Write(p[:n])
+	if err == io.EOF {
+		cb.eof = true
+	} else if err != nil && cb.err == nil {
+		cb.err = err
+	}
+	return n, err
+}
+
+func (cb *cachedBody) Close() error {
+	err := cb.rc.Close()
+	if cb.eof && cb.err == nil && cb.cw.verify(cb.fname) != nil {
+		os.Remove(cb.fname)
+	}
+	return err
+}
+
+func cacheOpen(fname string) (*Response, error) {
+	return FileFetcher{}.Fetch(context.Background(), fname)
+}
+
+// fetch: Download the URL, saving it as fname once all of it has been read,
+// and it matches the checksums in cw (if any).
+func (cf *cacheFetcher) fetch(ctx context.Context, url, fname string,
+	cw *chksWriter) (*Response, error) {
+	if cf.c.Offline {
+		return nil, fmt.Errorf("%w: %s", ErrNotCached, url)
+	}
+
+	resp, err := fetcher(cf.f).Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return cf.save(resp, fname, cw)
+}
+
+// save: Wrap the Body of resp so it's saved as fname, see fetch. The
+// validators are saved too, for anything that isn't kept by checksum.
+func (cf *cacheFetcher) save(resp *Response, fname string,
+	cw *chksWriter) (*Response, error) {
+	dir := filepath.Dir(fname)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	cb := &cacheBody{rc: resp.Body, tmp: tmp, fname: fname, cw: cw}
+	if cw == nil {
+		cb.etag = resp.ETag
+		cb.modTime = resp.ModTime
+	}
+	resp.Body = cb
+	return resp, nil
+}
+
+// readValidators: The ETag and Last-Modified time saved for fname, if any.
+func readValidators(fname string) (string, time.Time) {
+	data, err := ioutil.ReadFile(fname + ".validators")
+	if err != nil {
+		return "", time.Time{}
+	}
+
+	lines := strings.SplitN(string(data), "\n", 3)
+	var modTime time.Time
+	if len(lines) > 1 {
+		modTime, _ = time.Parse(time.RFC3339Nano, lines[1])
+	}
+	return lines[0], modTime
+}
+
+func writeValidators(fname string, etag string, modTime time.Time) error {
+	if etag == "" && modTime.IsZero() {
+		os.Remove(fname + ".validators")
+		return nil
+	}
+
+	var mt string
+	if !modTime.IsZero() {
+		mt = modTime.Format(time.RFC3339Nano)
+	}
+	data := etag + "\n" + mt + "\n"
+	return ioutil.WriteFile(fname+".validators", []byte(data), 0644)
+}
+
+// cacheBody: Copies the body to tmp, which is renamed to fname on Close if
+// it was all read without errors.
+type cacheBody struct {
+	rc    io.ReadCloser
+	tmp   *os.File
+	fname string
+	cw    *chksWriter
+	err   error
+	eof   bool
+
+	etag    string
+	modTime time.Time
+}
+
+func (cb *cacheBody) Read(p []byte) (int, error) {
+	n, err := cb.rc.Read(p)
+	if n > 0 && cb.err == nil {
+		_, cb.err = cb.tmp.Write(p[:n])
+		if cb.cw != nil {
+			cb.cw.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		cb.eof = true
+	} else if err != nil && cb.err == nil {
+		cb.err = err
+	}
+	return n, err
+}
+
+func (cb *cacheBody) Close() error {
+	err := cb.rc.Close()
+
+	ok := cb.eof && cb.err == nil
+	if ok && cb.cw != nil {
+		ok = cb.cw.verify(cb.fname) == nil
+	}
+	if cerr := cb.tmp.Close(); cerr != nil {
+		ok = false
+	}
+	if !ok || os.Rename(cb.tmp.Name(), cb.fname) != nil {
+		os.Remove(cb.tmp.Name())
+	} else if cb.cw == nil {
+		writeValidators(cb.fname, cb.etag, cb.modTime)
+	}
+
+	return err
+}
@@ -0,0 +1,279 @@
+package repos
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	dir := tREPODATA(t, nil)
+	defer os.RemoveAll(dir)
+
+	reqs := 0
+	conds := 0
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqs++
+			if r.Header.Get("If-Modified-Since") != "" {
+				conds++
+			}
+			http.FileServer(http.Dir(dir)).ServeHTTP(w, r)
+		}))
+	defer srv.Close()
+
+	cdir, err := ioutil.TempDir("", "repos-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cdir)
+
+	load := func(c *Cache) (*Pkgs, error) {
+		snap, err := BaseurlWith(c.Fetcher("test", nil), srv.URL)
+		if err != nil {
+			return nil, err
+		}
+		repomd, err := snap.RepoMD()
+		if err != nil {
+			return nil, err
+		}
+		return repomd.Load()
+	}
+
+	c := &Cache{Dir: cdir, Expire: -1}
+	for i, want := range []int{2, 2} {
+		pkgs, err := load(c)
+		if err != nil {
+			t.Fatalf("%d: %v\n", i, err)
+		}
+		if len(pkgs.Pkgs) != 2 || reqs != want {
+			t.Errorf("%d: got %d pkgs after %d requests, want 2 after %d\n",
+				i, len(pkgs.Pkgs), reqs, want)
+		}
+	}
+
+	// Expired, so repomd.xml is checked again but primary isn't.
+	c.Expire = 0
+	if _, err := load(c); err != nil || reqs != 3 || conds != 1 {
+		t.Errorf("expired: %v after %d requests (%d conditional)\n", err,
+			reqs, conds)
+	}
+
+	// Changed, so repomd.xml is downloaded again.
+	fname := filepath.Join(dir, "repodata", "repomd.xml")
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(fname, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := load(c); err != nil || reqs != 4 || conds != 2 {
+		t.Errorf("changed: %v after %d requests (%d conditional)\n", err,
+			reqs, conds)
+	}
+
+	srv.Close()
+	c.Offline = true
+	if pkgs, err := load(c); err != nil || len(pkgs.Pkgs) != 2 {
+		t.Errorf("offline: %v\n", err)
+	}
+
+	c.Dir = filepath.Join(cdir, "empty")
+	if _, err := load(c); !errors.Is(err, ErrNotCached) {
+		t.Errorf("offline empty: got %v, want ErrNotCached\n", err)
+	}
+}
+
+func TestCacheCorrupt(t *testing.T) {
+	good := tGZIP(tPRIMARY)
+	repo, counts, done := tMIRRORS(t, good, good, good)
+	defer done()
+
+	cdir, err := ioutil.TempDir("", "repos-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cdir)
+
+	c := &Cache{Dir: cdir, Expire: -1}
+	repo.Fetcher = c.Fetcher("test", nil)
+	if _, err := repo.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	cf := repo.Fetcher.(*cacheFetcher)
+	fname := cf.dataFile(repo.Primary)
+	if err := ioutil.WriteFile(fname, tGZIP("junk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first mirror gets the corrupt cached data, the second downloads it.
+	pkgs, err := repo.Load()
+	if err != nil || len(pkgs.Pkgs) != 2 {
+		t.Fatalf("corrupt: got %v\n", err)
+	}
+	if counts[0] != 1 || counts[1] != 1 {
+		t.Errorf("requests: got %v\n", counts)
+	}
+	if data, _ := ioutil.ReadFile(fname); string(data) != string(good) {
+		t.Errorf("cache wasn't replaced\n")
+	}
+
+	d := Data{Path: "repodata/primary.xml.gz",
+		Chks: []Checksum{{Kind: "../sha256", Data: "/../../x"}}}
+	if dir := filepath.Dir(cf.dataFile(d)); dir != filepath.Join(cf.dir, "data") {
+		t.Errorf("dataFile: got %s, want in %s\n", dir, cf.dir)
+	}
+}
+
+func TestCacheETag(t *testing.T) {
+	dir := tREPODATA(t, nil)
+	defer os.RemoveAll(dir)
+
+	etag := `"v1"`
+	var matches []string
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/repodata/repomd.xml" {
+				http.FileServer(http.Dir(dir)).ServeHTTP(w, r)
+				return
+			}
+			matches = append(matches, r.Header.Get("If-None-Match"))
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			http.ServeFile(w, r, filepath.Join(dir, "repodata", "repomd.xml"))
+		}))
+	defer srv.Close()
+
+	cdir, err := ioutil.TempDir("", "repos-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cdir)
+
+	c := &Cache{Dir: cdir}
+	f := c.Fetcher("test", &HTTPFetcher{})
+	url := srv.URL + "/repodata/repomd.xml"
+	fetch := func() string {
+		resp, err := f.Fetch(context.Background(), url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || len(data) == 0 {
+			t.Fatalf("fetch: got %d bytes, %v\n", len(data), err)
+		}
+
+		vfiles, _ := filepath.Glob(filepath.Join(cdir, "test", "*.validators"))
+		if len(vfiles) != 1 {
+			t.Fatalf("validators: got %v\n", vfiles)
+		}
+		vetag, _ := readValidators(strings.TrimSuffix(vfiles[0],
+			".validators"))
+		return vetag
+	}
+
+	// Downloaded, then not modified, then changed.
+	for i, want := range []string{`"v1"`, `"v1"`, `"v2"`} {
+		if i == 2 {
+			etag = `"v2"`
+		}
+		if vetag := fetch(); vetag != want {
+			t.Errorf("%d: saved ETag %s, want %s\n", i, vetag, want)
+		}
+	}
+
+	res := []string{"", `"v1"`, `"v1"`}
+	if len(matches) != len(res) {
+		t.Fatalf("If-None-Match: got %q\n", matches)
+	}
+	for i := range res {
+		if matches[i] != res[i] {
+			t.Errorf("If-None-Match %d: got %q, want %q\n", i, matches[i],
+				res[i])
+		}
+	}
+}
+
+func TestCacheClean(t *testing.T) {
+	var srvs []*httptest.Server
+	var repomds [][]byte
+	for _, primary := range []string{tPRIMARY,
+		strings.Replace(tPRIMARY, "<name>bar</name>", "<name>baz</name>", 1)} {
+		dir := tREPODATA(t, map[string]string{"primary": primary})
+		defer os.RemoveAll(dir)
+		repomd, err := ioutil.ReadFile(filepath.Join(dir, "repodata",
+			"repomd.xml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		repomds = append(repomds, repomd)
+
+		srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+		defer srv.Close()
+		srvs = append(srvs, srv)
+	}
+
+	cdir, err := ioutil.TempDir("", "repos-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cdir)
+
+	c := &Cache{Dir: cdir, Expire: -1}
+	f := c.Fetcher("test", nil)
+	data := func() []string {
+		fnames, _ := filepath.Glob(filepath.Join(cdir, "test", "data", "*"))
+		return fnames
+	}
+
+	snap, _ := BaseurlWith(f, srvs[0].URL)
+	repo, err := snap.RepoMD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Load(); err != nil {
+		t.Fatal(err)
+	}
+	old := data()
+	if len(old) != 1 {
+		t.Fatalf("data: got %v\n", old)
+	}
+
+	// The first mirror has the old repomd.xml cached, which is dropped, and
+	// the old primary isn't in the new one.
+	first := snap.URLs[0].URL
+	snap.URLs = append(snap.URLs, URL{URL: srvs[1].URL +
+		"/repodata/repomd.xml", Pri: 1, Protocol: "http"})
+	snap.Repomd.Chks = []Checksum{{Kind: "sha256",
+		Data: fmt.Sprintf("%x", sha256.Sum256(repomds[1]))}}
+	repo, err = snap.RepoMD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(f.(*cacheFetcher).urlFile(first)); !os.IsNotExist(err) {
+		t.Errorf("stale repomd.xml: got %v\n", err)
+	}
+	if fnames := data(); len(fnames) != 0 {
+		t.Errorf("data after repomd.xml: got %v\n", fnames)
+	}
+
+	pkgs, err := repo.Load()
+	if err != nil || len(pkgs.Pkgs) != 2 || pkgs.Pkgs[0].Name() != "baz" {
+		t.Fatalf("new: got %v\n", err)
+	}
+	if fnames := data(); len(fnames) != 1 || fnames[0] == old[0] {
+		t.Errorf("data after load: got %v\n", fnames)
+	}
+}
@@ -0,0 +1,52 @@
+package repos
+
+import (
+	"strings"
+)
+
+// Unresolved: A package and the requires of it that nothing provides.
+type Unresolved struct {
+	Pkg      *Pkg
+	Requires []Dependency
+}
+
+// provided: Is there any package providing the dependency, rich
+// dependencies that don't parse aren't provided.
+func (pkgs *Pkgs) provided(dep Dependency) bool {
+	if dep.IsRich() {
+		rd, err := ParseRichDep(dep.Name)
+		return err == nil && rd.Satisfied(pkgs)
+	}
+
+	return len(pkgs.WhatProvidesDep(dep).Pkgs) > 0
+}
+
+// Unresolved: The requires of the package that nothing in pkgs provides.
+// The rpmlib() requires are skipped, as rpm provides those itself. Rich
+// requires are unresolved if they are false, see RichDep.Satisfied.
+func (pkgs *Pkgs) Unresolved(pkg *Pkg) []Dependency {
+	var ret []Dependency
+	for _, dep := range pkg.requires {
+		if strings.HasPrefix(dep.Name, "rpmlib(") {
+			continue
+		}
+		if !pkgs.provided(dep) {
+			ret = append(ret, dep)
+		}
+	}
+
+	return ret
+}
+
+// Repoclosure: Like dnf repoclosure, find every package with requires that
+// nothing in pkgs provides. To check across repos, Merge them first.
+func (pkgs *Pkgs) Repoclosure() []Unresolved {
+	var ret []Unresolved
+	for _, p := range pkgs.Pkgs {
+		if deps := pkgs.Unresolved(p); len(deps) > 0 {
+			ret = append(ret, Unresolved{Pkg: p, Requires: deps})
+		}
+	}
+
+	return ret
+}
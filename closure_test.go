@@ -0,0 +1,50 @@
+package repos
+
+import "testing"
+
+func TestRepoclosure(t *testing.T) {
+	a := &Pkgs{Pkgs: []*Pkg{
+		tPKG(t, "app-1-1.x86_64", nil, []string{"rpmlib(PayloadIsXz) <= 5.2-1",
+			"/usr/bin/sh", "lib >= 2", "missing"}),
+		tPKG(t, "bash-5-1.x86_64"),
+	}}
+	a.Pkgs[1].files = []string{"/usr/bin/sh"}
+	b := &Pkgs{Pkgs: []*Pkg{
+		tPKG(t, "lib-2-1.x86_64", nil, []string{"/usr/bin/sh"}),
+	}}
+
+	// lib is in the other repo, and lib's /usr/bin/sh in this one.
+	data := []struct {
+		pkgs *Pkgs
+		res  []string
+	}{
+		{a, []string{"lib >= 2", "missing"}},
+		{a.Merge(b), []string{"missing"}},
+	}
+	for i := range data {
+		rc := data[i].pkgs.Repoclosure()
+		if len(rc) != 1 || rc[0].Pkg != a.Pkgs[0] {
+			t.Errorf("%d: Repoclosure: got %v\n", i, rc)
+			continue
+		}
+		if len(rc[0].Requires) != len(data[i].res) {
+			t.Errorf("%d: Requires\n  res = %v\n  ret = %v\n", i,
+				data[i].res, rc[0].Requires)
+			continue
+		}
+		for j, dep := range rc[0].Requires {
+			if dep.String() != data[i].res[j] {
+				t.Errorf("%d: Requires\n  res = %v\n  ret = %v\n", i,
+					data[i].res, rc[0].Requires)
+				break
+			}
+		}
+	}
+
+	if deps := a.Merge(b).Unresolved(b.Pkgs[0]); len(deps) != 0 {
+		t.Errorf("Unresolved(lib): got %v\n", deps)
+	}
+	if deps := b.Unresolved(b.Pkgs[0]); len(deps) != 1 {
+		t.Errorf("Unresolved(lib) alone: got %v\n", deps)
+	}
+}
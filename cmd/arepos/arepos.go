@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"regexp"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/james-antill/repos"
 )
@@ -18,17 +22,53 @@ type repoData struct {
 	name       string
 	url        string
 	mirrorlist bool
-	plain      bool
+	base       []string // Repos that requires can also be resolved from
 }
 type res struct {
 	name string
+	base []string
 	pkgs *repos.Pkgs
 	err  error
 }
 
 func main() {
+	var timeout time.Duration
+	var arches string
+	var compat bool
+	var latest int
+	var cache repos.Cache
+	flag.DurationVar(&timeout, "timeout", 5*time.Minute, "Set timeout per repo")
+	flag.StringVar(&arches, "arches", "", "Only show packages of these arches (comma separated)")
+	flag.BoolVar(&compat, "compat", false, "Only show packages compatible with "+defArch)
+	flag.IntVar(&latest, "latest-limit", 0, "Only show the N newest of each name.arch (negative for all but)")
+	flag.StringVar(&cache.Dir, "cachedir", "", "Cache metadata in this directory")
+	flag.DurationVar(&cache.Expire, "expire", 48*time.Hour, "Use cached metalinks and repomd.xml until this old (negative for forever)")
+	flag.BoolVar(&cache.Offline, "offline", false, "Only use cached metadata, with -cachedir")
 	flag.Parse()
 
+	cmd := "list"
+	args := flag.Args()
+	if len(args) > 0 {
+		cmd = args[0]
+		args = args[1:]
+	}
+
+	// searchchangelog takes a regexp, Eg. "CVE-[0-9]+-[0-9]+"
+	var re *regexp.Regexp
+	if cmd == "searchchangelog" {
+		if len(args) < 1 {
+			fmt.Println("error: searchchangelog needs a regexp")
+			return
+		}
+		var err error
+		re, err = regexp.Compile(args[0])
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+		args = args[1:]
+	}
+
 	d := []repoData{}
 	// Fedora repos...
 	for _, repo := range []string{"26", "27", "28"} {
@@ -40,17 +80,20 @@ func main() {
 	for _, repo := range []string{"26", "27", "28"} {
 		url := fmt.Sprintf("%s://%s?repo=updates-released-f%s&arch=%s", defScheme, defHost,
 			repo, defArch)
-		d = append(d, repoData{name: "Fedora Updates " + repo, url: url})
+		d = append(d, repoData{name: "Fedora Updates " + repo, url: url,
+			base: []string{"Fedora " + repo}})
 	}
 	for _, repo := range []string{"26", "27", "28"} {
 		url := fmt.Sprintf("%s://%s?repo=updates-testing-f%s&arch=%s", defScheme, defHost,
 			repo, defArch)
-		d = append(d, repoData{name: "Fedora Updates Tst " + repo, url: url})
+		d = append(d, repoData{name: "Fedora Updates Tst " + repo, url: url,
+			base: []string{"Fedora " + repo, "Fedora Updates " + repo}})
 	}
 	for _, repo := range []string{"28"} {
 		url := fmt.Sprintf("%s://%s?repo=updates-testing-modular-f%s&arch=%s", defScheme, defHost,
 			repo, defArch)
-		d = append(d, repoData{name: "Fedora Modular " + repo, url: url})
+		d = append(d, repoData{name: "Fedora Modular " + repo, url: url,
+			base: []string{"Fedora " + repo, "Fedora Updates " + repo}})
 	}
 	// Rawhide repo...
 	if true {
@@ -63,26 +106,28 @@ func main() {
 	for _, repo := range []string{"6", "7"} {
 		url := fmt.Sprintf("%s://%s?repo=epel-%s&arch=%s", defScheme, defHost,
 			repo, defArch)
-		d = append(d, repoData{name: "EPEL " + repo, url: url})
+		d = append(d, repoData{name: "EPEL " + repo, url: url,
+			base: []string{"CentOS " + repo, "CentOS Updates " + repo}})
 	}
 	// CentOS repos...
 	for _, repo := range []string{"6", "7"} {
-		// url := fmt.Sprintf("%s://mirrorlist.centos.org/?release=%s&arch=%s&repo=os&infra=%s",
-		url := fmt.Sprintf("%s://mirror.centos.org/centos/%s/%s/%s/",
-			"http", repo, "os", defArch)
-		d = append(d, repoData{name: "CentOS " + repo, url: url, plain: true})
+		url := fmt.Sprintf("%s://mirrorlist.centos.org/?release=%s&arch=%s&repo=%s&infra=%s",
+			"http", repo, defArch, "os", "stock")
+		d = append(d, repoData{name: "CentOS " + repo, url: url, mirrorlist: true})
 	}
 	// CentOS updates repos...
 	for _, repo := range []string{"6", "7"} {
-		url := fmt.Sprintf("%s://mirror.centos.org/centos/%s/%s/%s/",
-			"http", repo, "updates", defArch)
-		d = append(d, repoData{name: "CentOS Updates " + repo, url: url, plain: true})
+		url := fmt.Sprintf("%s://mirrorlist.centos.org/?release=%s&arch=%s&repo=%s&infra=%s",
+			"http", repo, defArch, "updates", "stock")
+		d = append(d, repoData{name: "CentOS Updates " + repo, url: url, mirrorlist: true,
+			base: []string{"CentOS " + repo}})
 	}
 	// CentOS CR repo...
 	for _, repo := range []string{"6", "7"} {
-		url := fmt.Sprintf("%s://mirror.centos.org/centos/%s/%s/%s/",
-			"http", repo, "cr", defArch)
-		d = append(d, repoData{name: "CentOS CR " + repo, url: url, plain: true})
+		url := fmt.Sprintf("%s://mirrorlist.centos.org/?release=%s&arch=%s&repo=%s&infra=%s",
+			"http", repo, defArch, "cr", "stock")
+		d = append(d, repoData{name: "CentOS CR " + repo, url: url, mirrorlist: true,
+			base: []string{"CentOS " + repo, "CentOS Updates " + repo}})
 	}
 
 	r := make(chan res, 4)
@@ -91,36 +136,48 @@ func main() {
 		rd := d[i]
 		wg.Add(1)
 		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
 			var snap *repos.Snapshot
 			var err error
+			var f repos.Fetcher
+			if cache.Dir != "" {
+				f = cache.Fetcher(rd.name, nil)
+			}
 
-			if rd.plain {
-				snap, err = repos.Baseurl(rd.url)
+			if rd.mirrorlist {
+				snap, err = repos.MirrorlistContext(ctx, f, rd.url)
 			} else { // Metalink...
-				snap, err = repos.Metalink(rd.url)
+				snap, err = repos.MetalinkContext(ctx, f, rd.url)
 			}
 			if err != nil {
 				r <- res{name: rd.name, pkgs: nil, err: err}
-				wg.Done()
 				return
 			}
 
-			repomd, err := snap.RepoMD()
+			repomd, err := snap.RepoMDContext(ctx)
 			if err != nil {
 				r <- res{name: rd.name, pkgs: nil, err: err}
-				wg.Done()
 				return
 			}
 
-			pkgs, err := repomd.Load()
+			pkgs, err := repomd.LoadContext(ctx)
 			if err != nil {
 				r <- res{name: rd.name, pkgs: nil, err: err}
-				wg.Done()
 				return
 			}
 
-			r <- res{name: rd.name, pkgs: pkgs}
-			wg.Done()
+			if re != nil {
+				if err := pkgs.LoadChangelogsContext(ctx); err != nil {
+					r <- res{name: rd.name, pkgs: nil, err: err}
+					return
+				}
+			}
+
+			r <- res{name: rd.name, base: rd.base, pkgs: pkgs}
 		}()
 	}
 
@@ -144,21 +201,60 @@ func main() {
 		})
 	}
 
-	cmd := "list"
-	args := flag.Args()
-	if len(args) > 0 {
-		cmd = args[0]
-		args = args[1:]
+	// These take capabilities, not package patterns.
+	var caps []string
+	if cmd == "whatprovides" || cmd == "whatrequires" {
+		caps = args
+		args = nil
 	}
 
-	if len(args) > 0 {
+	// diff takes the names of two of the repos, Eg. "Fedora 27" "Fedora 28".
+	var diff []string
+	if cmd == "diff" {
+		if len(args) < 2 {
+			fmt.Println("error: diff needs two repo names")
+			return
+		}
+		diff = args[:2]
+		args = args[2:]
+	}
+
+	// Requires are resolved against all of each repo, and its base repos.
+	var closure map[string]*repos.Pkgs
+	if cmd == "repoclosure" {
+		loaded := make(map[string]*repos.Pkgs, len(pkgs))
+		for i := range pkgs {
+			loaded[pkgs[i].name] = pkgs[i].pkgs
+		}
+
+		closure = make(map[string]*repos.Pkgs, len(pkgs))
 		for i := range pkgs {
-			rv := &pkgs[i]
-			mpkgs := &repos.Pkgs{Repo: rv.pkgs.Repo}
-			for _, arg := range args {
-				mpkgs = mpkgs.Merge(rv.pkgs.Match(arg))
+			all := pkgs[i].pkgs
+			for _, name := range pkgs[i].base {
+				if loaded[name] == nil {
+					fmt.Printf("error: %s needs %s, which didn't load\n",
+						pkgs[i].name, name)
+					continue
+				}
+				all = all.Merge(loaded[name])
 			}
-			rv.pkgs = mpkgs
+			closure[pkgs[i].name] = all
+		}
+	}
+
+	carch := ""
+	if compat {
+		carch = defArch
+	}
+	var archl []string
+	if arches != "" {
+		archl = strings.Split(arches, ",")
+	}
+	for i := range pkgs {
+		rv := &pkgs[i]
+		rv.pkgs = rv.pkgs.Filter(archl, carch, latest)
+		if len(args) > 0 {
+			rv.pkgs = rv.pkgs.MatchSpec(args...)
 		}
 	}
 
@@ -178,7 +274,85 @@ func main() {
 			fmt.Println(p.name)
 			for _, pkg := range p.pkgs.Pkgs {
 				fmt.Println("", pkg)
+				fmt.Println("  ", pkg.Summary())
 				fmt.Println("  ", pkg.Checksum())
+				if p.pkgs.Repo != nil {
+					fmt.Println("  ", p.pkgs.Repo.PkgURL(pkg))
+				}
+			}
+		}
+
+	case "whatprovides", "whatrequires":
+		for i := range pkgs {
+			p := &pkgs[i]
+			fmt.Println(p.name)
+			for _, c := range caps {
+				var wpkgs *repos.Pkgs
+				var err error
+				if cmd == "whatprovides" {
+					wpkgs, err = p.pkgs.WhatProvides(c)
+				} else {
+					wpkgs, err = p.pkgs.WhatRequires(c)
+				}
+				if err != nil {
+					fmt.Printf("error: %v\n", err)
+					return
+				}
+				for _, pkg := range wpkgs.Pkgs {
+					fmt.Println("", pkg)
+				}
+			}
+		}
+
+	case "repoclosure":
+		for i := range pkgs {
+			p := &pkgs[i]
+			fmt.Println(p.name)
+			all := closure[p.name]
+			for _, pkg := range p.pkgs.Pkgs {
+				deps := all.Unresolved(pkg)
+				if len(deps) == 0 {
+					continue
+				}
+				fmt.Println("", pkg)
+				for _, dep := range deps {
+					fmt.Println("  ", "unresolved:", dep)
+				}
+			}
+		}
+
+	case "diff":
+		var a, b *repos.Pkgs
+		for i := range pkgs {
+			if pkgs[i].name == diff[0] {
+				a = pkgs[i].pkgs
+			}
+			if pkgs[i].name == diff[1] {
+				b = pkgs[i].pkgs
+			}
+		}
+		if a == nil || b == nil {
+			fmt.Printf("error: no repos %q and %q\n", diff[0], diff[1])
+			return
+		}
+
+		fmt.Println(diff[0], "->", diff[1])
+		fmt.Print(repos.Diff(a, b))
+
+	case "searchchangelog":
+		for i := range pkgs {
+			p := &pkgs[i]
+			fmt.Println(p.name)
+			for _, pkg := range p.pkgs.Pkgs {
+				cls := pkg.SearchChangelogs(re)
+				if len(cls) == 0 {
+					continue
+				}
+				fmt.Println("", pkg)
+				for _, c := range cls {
+					fmt.Println("  ", c.Date.Format("2006-01-02"), c.Author)
+					fmt.Println("  ", strings.Replace(c.Text, "\n", "\n   ", -1))
+				}
 			}
 		}
 
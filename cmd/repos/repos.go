@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/james-antill/repos"
 )
@@ -13,27 +17,69 @@ const defHost = "mirrors.fedoraproject.org/metalink"
 const defArch = "x86_64"
 const defRepo = "fedora-28"
 
+// load: The packages from the baseurl, if given, else the repo's metalink.
+// The cache, if any, is per repo and arch (or baseurl).
+func load(ctx context.Context, cache *repos.Cache, repo, arch,
+	baseurl string) (*repos.Pkgs, error) {
+	var f repos.Fetcher
+	var snap *repos.Snapshot
+	var err error
+	if baseurl != "" {
+		fmt.Println("URL:", baseurl)
+		if cache.Dir != "" {
+			f = cache.Fetcher(baseurl, nil)
+		}
+		snap, err = repos.BaseurlWith(f, baseurl)
+	} else {
+		url := fmt.Sprintf("%s://%s?repo=%s&arch=%s", defScheme, defHost, repo, arch)
+		fmt.Println("URL:", url)
+		if cache.Dir != "" {
+			f = cache.Fetcher(repo+"-"+arch, nil)
+		}
+		snap, err = repos.MetalinkContext(ctx, f, url)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	repomd, err := snap.RepoMDContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// fmt.Println(repomd)
+
+	return repomd.LoadContext(ctx)
+}
+
 func main() {
 	var repo string
+	var arch string
+	var baseurl string
+	var timeout time.Duration
+	var arches string
+	var compat bool
+	var latest int
+	var cache repos.Cache
 	flag.StringVar(&repo, "repo", defRepo, "Set repo")
+	flag.StringVar(&arch, "arch", defArch, "Set arch")
+	flag.StringVar(&baseurl, "baseurl", "", "Set baseurl (or path), instead of repo")
+	flag.DurationVar(&timeout, "timeout", 5*time.Minute, "Set timeout")
+	flag.StringVar(&arches, "arches", "", "Only show packages of these arches (comma separated)")
+	flag.BoolVar(&compat, "compat", false, "Only show packages compatible with -arch")
+	flag.IntVar(&latest, "latest-limit", 0, "Only show the N newest of each name.arch (negative for all but)")
+	flag.StringVar(&cache.Dir, "cachedir", "", "Cache metadata in this directory")
+	flag.DurationVar(&cache.Expire, "expire", 48*time.Hour, "Use cached metalinks and repomd.xml until this old (negative for forever)")
+	flag.BoolVar(&cache.Offline, "offline", false, "Only use cached metadata, with -cachedir")
 	flag.Parse()
 
-	url := fmt.Sprintf("%s://%s?repo=%s&arch=%s", defScheme, defHost, repo, defArch)
-	fmt.Println("URL:", url)
-	snap, err := repos.Metalink(url)
-	if err != nil {
-		fmt.Printf("error: %v", err)
-		os.Exit(1)
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	repomd, err := snap.RepoMD()
+	pkgs, err := load(ctx, &cache, repo, arch, baseurl)
 	if err != nil {
 		fmt.Printf("error: %v", err)
 		os.Exit(1)
 	}
-	// fmt.Println(repomd)
-
-	pkgs, err := repomd.Load()
 
 	cmd := "list"
 	args := flag.Args()
@@ -42,12 +88,101 @@ func main() {
 		args = args[1:]
 	}
 
-	if len(args) > 0 {
-		mpkgs := &repos.Pkgs{Repo: pkgs.Repo}
-		for _, arg := range args {
-			mpkgs = mpkgs.Merge(pkgs.Match(arg))
+	// These take capabilities, specs or paths, not package patterns.
+	var caps []string
+	if cmd == "whatprovides" || cmd == "whatrequires" || cmd == "solve" ||
+		cmd == "whatowns" {
+		caps = args
+		args = nil
+	}
+
+	// diff takes the other repo, or a baseurl (or path) with a /.
+	var other *repos.Pkgs
+	if cmd == "diff" {
+		if len(args) < 1 {
+			fmt.Println("error: diff needs a repo or baseurl")
+			os.Exit(1)
+		}
+		if strings.Contains(args[0], "/") {
+			other, err = load(ctx, &cache, "", arch, args[0])
+		} else {
+			other, err = load(ctx, &cache, args[0], arch, "")
+		}
+		if err != nil {
+			fmt.Printf("error: %v", err)
+			os.Exit(1)
+		}
+		args = args[1:]
+	}
+
+	// searchchangelog takes a regexp, Eg. "CVE-[0-9]+-[0-9]+"
+	var re *regexp.Regexp
+	if cmd == "searchchangelog" {
+		if len(args) < 1 {
+			fmt.Println("error: searchchangelog needs a regexp")
+			os.Exit(1)
 		}
-		pkgs = mpkgs
+		re, err = regexp.Compile(args[0])
+		if err != nil {
+			fmt.Printf("error: %v", err)
+			os.Exit(1)
+		}
+		args = args[1:]
+	}
+
+	// updateinfo takes an advisory type and a date, Eg. security 2018-06-01
+	var advs repos.Advisories
+	if cmd == "updateinfo" {
+		advs, err = pkgs.Repo.LoadUpdateinfoContext(ctx)
+		if err != nil {
+			fmt.Printf("error: %v", err)
+			os.Exit(1)
+		}
+		if len(args) > 0 {
+			advs = advs.OfType(args[0])
+		}
+		if len(args) > 1 {
+			since, err := time.Parse("2006-01-02", args[1])
+			if err != nil {
+				fmt.Printf("error: %v", err)
+				os.Exit(1)
+			}
+			advs = advs.Since(since)
+		}
+		args = nil
+	}
+
+	if cmd == "files" || cmd == "whatowns" {
+		if err := pkgs.LoadFilesContext(ctx); err != nil {
+			fmt.Printf("error: %v", err)
+			os.Exit(1)
+		}
+	}
+	if cmd == "changelog" || cmd == "searchchangelog" {
+		if err := pkgs.LoadChangelogsContext(ctx); err != nil {
+			fmt.Printf("error: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// Requires are resolved against all of the repo.
+	all := pkgs
+
+	carch := ""
+	if compat {
+		carch = arch
+	}
+	var archl []string
+	if arches != "" {
+		archl = strings.Split(arches, ",")
+	}
+	pkgs = pkgs.Filter(archl, carch, latest)
+	if other != nil {
+		other = other.Filter(archl, carch, latest)
+	}
+
+	if len(args) > 0 {
+		pkgs = pkgs.MatchSpec(args...)
 	}
 
 	switch cmd {
@@ -58,8 +193,129 @@ func main() {
 	case "info":
 		for _, pkg := range pkgs.Pkgs {
 			fmt.Println(pkg)
+			fmt.Println("", pkg.Summary())
 			fmt.Println("", pkg.Checksum())
+			fmt.Println("", "Size:", pkg.PackageSize(), "Installed:", pkg.InstalledSize())
+			fmt.Println("", "License:", pkg.License())
+			fmt.Println("", "URL:", pkg.URL())
+			fmt.Println("", "Source:", pkg.SourceRPM())
+			fmt.Println("", "Built:", pkg.BuildTime())
+			if pkgs.Repo != nil {
+				fmt.Println("", "Download:", pkgs.Repo.PkgURL(pkg))
+			}
+		}
+
+	case "deps":
+		for _, pkg := range pkgs.Pkgs {
+			fmt.Println(pkg)
+			for _, dep := range pkg.Provides() {
+				fmt.Println("", "provides:", dep)
+			}
+			for _, dep := range pkg.Requires() {
+				fmt.Println("", "requires:", dep)
+			}
+			for _, dep := range pkg.Conflicts() {
+				fmt.Println("", "conflicts:", dep)
+			}
+			for _, dep := range pkg.Obsoletes() {
+				fmt.Println("", "obsoletes:", dep)
+			}
+		}
+
+	case "files":
+		for _, pkg := range pkgs.Pkgs {
+			fmt.Println(pkg)
+			for _, f := range pkg.Files() {
+				if f.Type != "" {
+					fmt.Println("", f.Path, "("+f.Type+")")
+				} else {
+					fmt.Println("", f.Path)
+				}
+			}
+		}
+
+	case "changelog", "searchchangelog":
+		for _, pkg := range pkgs.Pkgs {
+			cls := pkg.Changelogs()
+			if re != nil {
+				cls = pkg.SearchChangelogs(re)
+				if len(cls) == 0 {
+					continue
+				}
+			}
+			fmt.Println(pkg)
+			for _, c := range cls {
+				fmt.Println("", c.Date.Format("2006-01-02"), c.Author)
+				fmt.Println("", strings.Replace(c.Text, "\n", "\n ", -1))
+			}
+		}
+
+	case "updateinfo":
+		for _, adv := range advs {
+			fmt.Println(adv.ID, adv.Type, adv.Severity)
+			fmt.Println("", adv.Title)
+			fmt.Println("", "Issued:", adv.Issued.Format("2006-01-02"))
+			for _, ref := range adv.References {
+				fmt.Println("", ref.Type+":", ref.ID)
+			}
+			for _, coll := range adv.Collections {
+				for _, n := range coll.Pkgs {
+					fmt.Println("", "package:", n)
+				}
+			}
+		}
+
+	case "whatowns":
+		for _, c := range caps {
+			for _, pkg := range pkgs.WhatOwns(c).Pkgs {
+				fmt.Println(pkg)
+			}
+		}
+
+	case "whatprovides", "whatrequires":
+		for _, c := range caps {
+			var wpkgs *repos.Pkgs
+			if cmd == "whatprovides" {
+				wpkgs, err = pkgs.WhatProvides(c)
+			} else {
+				wpkgs, err = pkgs.WhatRequires(c)
+			}
+			if err != nil {
+				fmt.Printf("error: %v", err)
+				os.Exit(1)
+			}
+			for _, pkg := range wpkgs.Pkgs {
+				fmt.Println(pkg)
+			}
+		}
+
+	case "repoclosure":
+		for _, pkg := range pkgs.Pkgs {
+			deps := all.Unresolved(pkg)
+			if len(deps) == 0 {
+				continue
+			}
+			fmt.Println(pkg)
+			for _, dep := range deps {
+				fmt.Println("", "unresolved:", dep)
+			}
+		}
+
+	case "solve":
+		spkgs, err := all.Solve(caps, arch)
+		if err != nil {
+			fmt.Printf("error: %v", err)
+			os.Exit(1)
+		}
+		for _, pkg := range spkgs.Pkgs {
+			fmt.Println(pkg)
+		}
+
+	case "diff":
+		if len(args) > 0 {
+			other = other.MatchSpec(args...)
 		}
+		fmt.Print(repos.Diff(pkgs, other))
 
 	case "rpmdbversion":
 		fmt.Println(pkgs.RPMDBVersion())
@@ -0,0 +1,172 @@
+package repos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DepFlags: How a Dependency compares against its EVR, 0 means any EVR.
+type DepFlags int
+
+const (
+	DepLT DepFlags = 1 << iota
+	DepGT
+	DepEQ
+
+	DepLE = DepLT | DepEQ
+	DepGE = DepGT | DepEQ
+)
+
+func (flags DepFlags) String() string {
+	switch flags {
+	case DepLT:
+		return "<"
+	case DepGT:
+		return ">"
+	case DepEQ:
+		return "="
+	case DepLE:
+		return "<="
+	case DepGE:
+		return ">="
+	}
+	return ""
+}
+
+// parseDepFlags: From the primary.xml names.
+func parseDepFlags(flags string) DepFlags {
+	switch flags {
+	case "LT":
+		return DepLT
+	case "GT":
+		return DepGT
+	case "EQ":
+		return DepEQ
+	case "LE":
+		return DepLE
+	case "GE":
+		return DepGE
+	}
+	return 0
+}
+
+// Dependency: An entry in a packages provides, requires, etc.
+type Dependency struct {
+	Name  string
+	Flags DepFlags
+	EVR   EVR
+	Pre   bool // Requires that are needed before the package is installed
+}
+
+func (dep Dependency) String() string {
+	if dep.Flags == 0 {
+		return dep.Name
+	}
+	return fmt.Sprintf("%s %s %s", dep.Name, dep.Flags, dep.EVR)
+}
+
+var depOps = map[string]DepFlags{
+	"<": DepLT, ">": DepGT, "=": DepEQ, "==": DepEQ, "<=": DepLE, ">=": DepGE,
+}
+
+// ParseDependency: From "name", or "name OP EVR" where OP is one of
+// <, >, =, <= or >=, the spaces around OP are optional. Rich dependencies,
+// in (), are kept whole as the name.
+func ParseDependency(s string) (Dependency, error) {
+	var dep Dependency
+
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "(") {
+		dep.Name = s
+		return dep, nil
+	}
+
+	i := strings.IndexAny(s, "<>=")
+	if i == -1 {
+		if s == "" || strings.ContainsAny(s, " \t") {
+			return dep, fmt.Errorf("bad dependency: %s", s)
+		}
+		dep.Name = s
+		return dep, nil
+	}
+
+	j := i
+	for j < len(s) && strings.IndexByte("<>=", s[j]) != -1 {
+		j++
+	}
+	name := strings.TrimSpace(s[:i])
+	evrs := strings.TrimSpace(s[j:])
+	if name == "" || evrs == "" || strings.ContainsAny(name, " \t") ||
+		strings.ContainsAny(evrs, " \t") {
+		return dep, fmt.Errorf("bad dependency: %s", s)
+	}
+	flags, ok := depOps[s[i:j]]
+	if !ok {
+		return dep, fmt.Errorf("bad operator in dependency: %s", s)
+	}
+	evr, err := ParseEVR(evrs)
+	if err != nil {
+		return dep, err
+	}
+
+	dep.Name = name
+	dep.Flags = flags
+	dep.EVR = evr
+	return dep, nil
+}
+
+// RangesOverlap: Are there any EVRs in both ranges, like rpmdsCompare. A
+// range with no flags, or no version, is all EVRs. Releases are only
+// compared when both ranges have one, and a missing epoch is 0.
+func RangesOverlap(aflags DepFlags, a EVR, bflags DepFlags, b EVR) bool {
+	if aflags == 0 || bflags == 0 || a.Version == "" || b.Version == "" {
+		return true
+	}
+
+	sense := a.Compare(b)
+	switch {
+	case sense < 0:
+		return aflags&DepGT != 0 || bflags&DepLT != 0
+	case sense > 0:
+		return aflags&DepLT != 0 || bflags&DepGT != 0
+	}
+	return (aflags&DepEQ != 0 && bflags&DepEQ != 0) ||
+		(aflags&DepLT != 0 && bflags&DepLT != 0) ||
+		(aflags&DepGT != 0 && bflags&DepGT != 0)
+}
+
+// Overlaps: Do the dependencies have the same name and overlapping ranges,
+// Eg. does a provide satisfy a require.
+func (dep Dependency) Overlaps(o Dependency) bool {
+	return dep.Name == o.Name && dep.overlaps(o)
+}
+
+// overlaps: Overlaps, for dependencies known to have the same name.
+func (dep Dependency) overlaps(o Dependency) bool {
+	return RangesOverlap(dep.Flags, dep.EVR, o.Flags, o.EVR)
+}
+
+func (pkg *Pkg) Provides() []Dependency {
+	return pkg.provides
+}
+func (pkg *Pkg) Requires() []Dependency {
+	return pkg.requires
+}
+func (pkg *Pkg) Conflicts() []Dependency {
+	return pkg.conflicts
+}
+func (pkg *Pkg) Obsoletes() []Dependency {
+	return pkg.obsoletes
+}
+func (pkg *Pkg) Recommends() []Dependency {
+	return pkg.recommends
+}
+func (pkg *Pkg) Suggests() []Dependency {
+	return pkg.suggests
+}
+func (pkg *Pkg) Supplements() []Dependency {
+	return pkg.supplements
+}
+func (pkg *Pkg) Enhances() []Dependency {
+	return pkg.enhances
+}
@@ -0,0 +1,113 @@
+package repos
+
+import (
+	"testing"
+)
+
+func tOVERLAP(t *testing.T, a, b string, res bool) {
+	da, err := ParseDependency(a)
+	if err != nil {
+		t.Fatalf("ParseDependency(%s): %v\n", a, err)
+	}
+	db, err := ParseDependency(b)
+	if err != nil {
+		t.Fatalf("ParseDependency(%s): %v\n", b, err)
+	}
+
+	if ret := da.Overlaps(db); ret != res {
+		t.Errorf("Overlaps(%s, %s)\n  res = %v\n  ret = %v\n", a, b, res, ret)
+	}
+	if ret := db.Overlaps(da); ret != res {
+		t.Errorf("Overlaps(%s, %s)\n  res = %v\n  ret = %v\n", b, a, res, ret)
+	}
+}
+
+// These follow rpmdsCompare() in lib/rpmds.c from
+// https://github.com/rpm-software-management/rpm/
+func TestOverlapsBasic(t *testing.T) {
+	tOVERLAP(t, "foo", "foo", true)
+	tOVERLAP(t, "foo", "bar", false)
+	tOVERLAP(t, "foo = 1.0", "bar = 1.0", false)
+	tOVERLAP(t, "foo", "foo >= 3.0", true)
+	tOVERLAP(t, "foo = 1.0", "foo", true)
+
+	tOVERLAP(t, "foo = 2.0", "foo >= 2.0", true)
+	tOVERLAP(t, "foo = 2.0", "foo > 2.0", false)
+	tOVERLAP(t, "foo = 2.0", "foo <= 2.0", true)
+	tOVERLAP(t, "foo = 2.0", "foo < 2.0", false)
+	tOVERLAP(t, "foo = 2.0", "foo = 2.0", true)
+	tOVERLAP(t, "foo = 2.0", "foo = 2.1", false)
+	tOVERLAP(t, "foo = 2.1", "foo > 2.0", true)
+	tOVERLAP(t, "foo = 1.9", "foo > 2.0", false)
+	tOVERLAP(t, "foo = 1.9", "foo < 2.0", true)
+}
+
+func TestOverlapsRanges(t *testing.T) {
+	tOVERLAP(t, "foo < 2.0", "foo > 1.0", true)
+	tOVERLAP(t, "foo < 1.0", "foo > 2.0", false)
+	tOVERLAP(t, "foo <= 1.0", "foo >= 1.0", true)
+	tOVERLAP(t, "foo < 1.0", "foo >= 1.0", false)
+	tOVERLAP(t, "foo <= 1.0", "foo > 1.0", false)
+	tOVERLAP(t, "foo < 1.0", "foo < 2.0", true)
+	tOVERLAP(t, "foo > 1.0", "foo > 2.0", true)
+	tOVERLAP(t, "foo >= 1.0", "foo <= 1.0", true)
+}
+
+func TestOverlapsEpoch(t *testing.T) {
+	tOVERLAP(t, "foo = 1:1.0", "foo >= 2.0", true)
+	tOVERLAP(t, "foo = 1.0", "foo >= 1:0.1", false)
+	tOVERLAP(t, "foo = 0:1.0", "foo = 1.0", true)
+	tOVERLAP(t, "foo = 1:2.0-3", "foo >= 2.0", true)
+	tOVERLAP(t, "foo = 1:2.0-3", "foo < 1:2.0", false)
+}
+
+func TestOverlapsRelease(t *testing.T) {
+	// No release on either side means any release matches.
+	tOVERLAP(t, "foo = 1.0", "foo = 1.0-1", true)
+	tOVERLAP(t, "foo = 1.0-1", "foo = 1.0-2", false)
+	tOVERLAP(t, "foo = 1.0-1", "foo >= 1.0", true)
+	tOVERLAP(t, "foo = 1.0-1", "foo > 1.0", false)
+	tOVERLAP(t, "foo = 1.0-1", "foo < 1.0", false)
+	tOVERLAP(t, "foo = 1.0-2", "foo > 1.0-1", true)
+	tOVERLAP(t, "foo = 1.0-2", "foo < 1.0-10", true)
+}
+
+func TestOverlapsTildeCaret(t *testing.T) {
+	if !tilde || !caret {
+		t.SkipNow()
+	}
+	tOVERLAP(t, "foo = 1.0~rc1", "foo >= 1.0", false)
+	tOVERLAP(t, "foo = 1.0~rc1", "foo < 1.0", true)
+	tOVERLAP(t, "foo = 1.0^git1", "foo > 1.0", true)
+	tOVERLAP(t, "foo = 1.0^git1", "foo < 1.0.1", true)
+	tOVERLAP(t, "foo = 1.0~rc1^git1", "foo > 1.0~rc1", true)
+	tOVERLAP(t, "foo = 1.0~rc1^git1", "foo < 1.0", true)
+}
+
+func TestParseDependency(t *testing.T) {
+	data := []struct {
+		s   string
+		dep string
+	}{
+		{"foo", "foo"},
+		{"foo >= 1.2", "foo >= 1.2"},
+		{"foo>=1.2", "foo >= 1.2"},
+		{"foo>= 1:1.2-3", "foo >= 1:1.2-3"},
+		{"libc.so.6()(64bit)", "libc.so.6()(64bit)"},
+		{"(foo if bar)", "(foo if bar)"},
+	}
+	for i := range data {
+		dep, err := ParseDependency(data[i].s)
+		if err != nil || dep.String() != data[i].dep {
+			t.Errorf("ParseDependency(%s)\n  res = %s\n  ret = %s (%v)\n",
+				data[i].s, data[i].dep, dep, err)
+		}
+	}
+
+	for _, s := range []string{"", "foo bar", "foo => 1", ">= 1", "foo >=",
+		"foo >= 1 2"} {
+		if _, err := ParseDependency(s); err == nil {
+			t.Errorf("ParseDependency(%s) didn't fail\n", s)
+		}
+	}
+}
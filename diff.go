@@ -0,0 +1,88 @@
+package repos
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// PkgChange: A name.arch that is in both Pkgs, with different versions.
+type PkgChange struct {
+	Old *Pkg
+	New *Pkg
+}
+
+// PkgsDiff: What changed between two Pkgs, by name.arch. All sorted.
+type PkgsDiff struct {
+	Added      []*Pkg
+	Removed    []*Pkg
+	Upgraded   []PkgChange
+	Downgraded []PkgChange
+}
+
+// newestNA: The newest package of each name.arch.
+func newestNA(pkgs *Pkgs) map[string]*Pkg {
+	ret := make(map[string]*Pkg)
+	for _, p := range pkgs.Pkgs {
+		o := ret[p.Na()]
+		if o == nil || p.Cmp(o) > 0 {
+			ret[p.Na()] = p
+		}
+	}
+	return ret
+}
+
+// Diff: The changes from a to b, comparing the newest package of each
+// name.arch. Packages with the same EVR, even if rebuilt, are unchanged.
+func Diff(a, b *Pkgs) *PkgsDiff {
+	anas := newestNA(a)
+	bnas := newestNA(b)
+
+	ret := &PkgsDiff{}
+	for na, ap := range anas {
+		bp := bnas[na]
+		switch {
+		case bp == nil:
+			ret.Removed = append(ret.Removed, ap)
+		case ap.EVR().Compare(bp.EVR()) == 0:
+		case ap.Cmp(bp) < 0:
+			ret.Upgraded = append(ret.Upgraded, PkgChange{Old: ap, New: bp})
+		default:
+			ret.Downgraded = append(ret.Downgraded, PkgChange{Old: ap, New: bp})
+		}
+	}
+	for na, bp := range bnas {
+		if anas[na] == nil {
+			ret.Added = append(ret.Added, bp)
+		}
+	}
+
+	sort.Sort(ByPkg(ret.Added))
+	sort.Sort(ByPkg(ret.Removed))
+	for _, chs := range [][]PkgChange{ret.Upgraded, ret.Downgraded} {
+		sort.Slice(chs, func(i, j int) bool {
+			return chs[i].New.Cmp(chs[j].New) < 0
+		})
+	}
+
+	return ret
+}
+
+// String: A line for each change, like " upgraded: old -> new", sorted as
+// in the PkgsDiff.
+func (d *PkgsDiff) String() string {
+	var buf bytes.Buffer
+	for _, pkg := range d.Added {
+		fmt.Fprintln(&buf, "", "added:", pkg)
+	}
+	for _, pkg := range d.Removed {
+		fmt.Fprintln(&buf, "", "removed:", pkg)
+	}
+	for _, ch := range d.Upgraded {
+		fmt.Fprintln(&buf, "", "upgraded:", ch.Old, "->", ch.New)
+	}
+	for _, ch := range d.Downgraded {
+		fmt.Fprintln(&buf, "", "downgraded:", ch.Old, "->", ch.New)
+	}
+	return buf.String()
+}
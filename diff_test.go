@@ -0,0 +1,37 @@
+package repos
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	a := &Pkgs{Pkgs: []*Pkg{
+		{name: "bar", version: "1", release: "1", arch: "noarch"},
+		{name: "foo", version: "1.2", release: "1", arch: "i686"},
+		{name: "foo", version: "1.2", release: "1", arch: "x86_64"},
+		{name: "old", version: "1", release: "1", arch: "x86_64"},
+		{name: "same", version: "1", release: "1", arch: "x86_64"},
+	}}
+	b := &Pkgs{Pkgs: []*Pkg{
+		{name: "bar", epoch: 1, version: "0.1", release: "1", arch: "noarch"},
+		{name: "foo", version: "1.1", release: "1", arch: "i686"},
+		{name: "foo", version: "1.2", release: "1", arch: "x86_64"},
+		{name: "foo", version: "1.2", release: "2", arch: "x86_64"},
+		{name: "new", version: "1", release: "1", arch: "x86_64"},
+		{name: "same", version: "1", release: "1", arch: "x86_64",
+			chk: Checksum{Kind: "sha256", Data: "rebuilt"}},
+	}}
+
+	d := Diff(a, b)
+	if len(d.Added) != 1 || d.Added[0].Nvra() != "new-1-1.x86_64" {
+		t.Errorf("Added: %v\n", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Nvra() != "old-1-1.x86_64" {
+		t.Errorf("Removed: %v\n", d.Removed)
+	}
+	if len(d.Upgraded) != 2 || d.Upgraded[0].New.Nevra() != "bar-1:0.1-1.noarch" ||
+		d.Upgraded[1].New.Nvra() != "foo-1.2-2.x86_64" {
+		t.Errorf("Upgraded: %v\n", d.Upgraded)
+	}
+	if len(d.Downgraded) != 1 || d.Downgraded[0].Old.Nvra() != "foo-1.2-1.i686" {
+		t.Errorf("Downgraded: %v\n", d.Downgraded)
+	}
+}
@@ -0,0 +1,82 @@
+package repos
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EVR: Epoch, version and release. An empty Release means any release.
+type EVR struct {
+	Epoch   int
+	Version string
+	Release string
+}
+
+// ParseEVR: From "E:V-R", where the "E:" and "-R" are optional.
+func ParseEVR(evrs string) (EVR, error) {
+	var evr EVR
+
+	s := evrs
+	if i := strings.IndexByte(s, ':'); i != -1 {
+		epoch, err := strconv.Atoi(s[:i])
+		if err != nil || epoch < 0 {
+			return evr, fmt.Errorf("bad epoch in EVR: %s", evrs)
+		}
+		evr.Epoch = epoch
+		s = s[i+1:]
+	}
+	if i := strings.LastIndexByte(s, '-'); i != -1 {
+		evr.Release = s[i+1:]
+		s = s[:i]
+	}
+	evr.Version = s
+	if evr.Version == "" {
+		return evr, fmt.Errorf("no version in EVR: %s", evrs)
+	}
+
+	return evr, nil
+}
+
+// String: As "E:V-R", the epoch is only shown when it isn't 0.
+func (evr EVR) String() string {
+	ret := evr.Version
+	if evr.Epoch != 0 {
+		ret = fmt.Sprintf("%d:%s", evr.Epoch, ret)
+	}
+	if evr.Release != "" {
+		ret += "-" + evr.Release
+	}
+	return ret
+}
+
+// Compare: Like rpm, the releases are only compared when both have one.
+// Returns -1, 0 or 1.
+func (evr EVR) Compare(o EVR) int {
+	if evr.Epoch != o.Epoch {
+		if evr.Epoch < o.Epoch {
+			return -1
+		}
+		return 1
+	}
+
+	ret := sign(Vercmp(evr.Version, o.Version))
+	if ret != 0 {
+		return ret
+	}
+
+	if evr.Release == "" || o.Release == "" {
+		return 0
+	}
+	return sign(Vercmp(evr.Release, o.Release))
+}
+
+func sign(i int) int {
+	switch {
+	case i < 0:
+		return -1
+	case i > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package repos
+
+import "testing"
+
+func TestEVR(t *testing.T) {
+	data := []struct {
+		s   string
+		evr EVR
+		str string
+	}{
+		{"1.0", EVR{0, "1.0", ""}, "1.0"},
+		{"1.0-1", EVR{0, "1.0", "1"}, "1.0-1"},
+		{"0:1.0-1.fc28", EVR{0, "1.0", "1.fc28"}, "1.0-1.fc28"},
+		{"2:1.0~rc1-0.1", EVR{2, "1.0~rc1", "0.1"}, "2:1.0~rc1-0.1"},
+	}
+
+	for i := range data {
+		evr, err := ParseEVR(data[i].s)
+		if err != nil || evr != data[i].evr {
+			t.Errorf("ParseEVR(%s)\n  res = %v\n  ret = %v (%v)\n",
+				data[i].s, data[i].evr, evr, err)
+		}
+		if evr.String() != data[i].str {
+			t.Errorf("String(%s)\n  res = %s\n  ret = %s\n",
+				data[i].s, data[i].str, evr.String())
+		}
+	}
+
+	for _, s := range []string{"", "a:1.0", "1:", "-1"} {
+		if _, err := ParseEVR(s); err == nil {
+			t.Errorf("ParseEVR(%s) didn't fail\n", s)
+		}
+	}
+
+	cmps := []struct {
+		a, b string
+		res  int
+	}{
+		{"1.0-1", "1.0-1", 0},
+		{"1.0-1", "1.0-2", -1},
+		{"1.0", "1.0-2", 0},
+		{"1:1.0-1", "2.0-1", 1},
+		{"1.0~rc1-1", "1.0-1", -1},
+	}
+	for i := range cmps {
+		a, _ := ParseEVR(cmps[i].a)
+		b, _ := ParseEVR(cmps[i].b)
+		if ret := a.Compare(b); ret != cmps[i].res {
+			t.Errorf("Compare(%s, %s)\n  res = %d\n  ret = %d\n",
+				cmps[i].a, cmps[i].b, cmps[i].res, ret)
+		}
+	}
+}
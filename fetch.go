@@ -0,0 +1,204 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Response: The data from a Fetcher, the caller must close the Body.
+type Response struct {
+	Body    io.ReadCloser
+	Size    int64     // -1 if unknown
+	ModTime time.Time // Zero if unknown
+	ETag    string    // Empty if unknown
+
+	// NotModified: From FetchIf, the data hasn't changed and there's no Body.
+	NotModified bool
+}
+
+// Fetcher: Retrieves the data at a URL.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (*Response, error)
+}
+
+// ConditionalFetcher: A Fetcher that can skip data that hasn't changed since
+// the etag or modTime, from a previous Response, either can be empty.
+type ConditionalFetcher interface {
+	Fetcher
+	FetchIf(ctx context.Context, url string, etag string,
+		modTime time.Time) (*Response, error)
+}
+
+// HTTPFetcher: Fetcher using a http.Client, nil means http.DefaultClient.
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+func (hf *HTTPFetcher) Fetch(ctx context.Context, url string) (*Response, error) {
+	return hf.FetchIf(ctx, url, "", time.Time{})
+}
+
+// FetchIf: Using If-None-Match and If-Modified-Since.
+func (hf *HTTPFetcher) FetchIf(ctx context.Context, url string, etag string,
+	modTime time.Time) (*Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if !modTime.IsZero() {
+		req.Header.Set("If-Modified-Since", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	client := hf.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified &&
+		(etag != "" || !modTime.IsZero()) {
+		resp.Body.Close()
+		return &Response{Size: -1, ModTime: modTime, ETag: etag,
+			NotModified: true}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err = fmt.Errorf("non-200 status (%s): %s", url, resp.Status)
+		return nil, err
+	}
+
+	ret := &Response{Body: resp.Body, Size: resp.ContentLength,
+		ETag: resp.Header.Get("ETag")}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		if tm, err := http.ParseTime(lm); err == nil {
+			ret.ModTime = tm
+		}
+	}
+
+	return ret, nil
+}
+
+// FileFetcher: Fetcher for file:// URLs and plain filesystem paths.
+type FileFetcher struct{}
+
+func (ff FileFetcher) Fetch(ctx context.Context, url string) (*Response, error) {
+	return ff.FetchIf(ctx, url, "", time.Time{})
+}
+
+// FetchIf: Using the modification time of the file, etag is ignored.
+func (FileFetcher) FetchIf(ctx context.Context, url string, etag string,
+	modTime time.Time) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path := url
+	if strings.HasPrefix(url, "file://") {
+		u, err := neturl.Parse(url)
+		if err != nil {
+			return nil, err
+		}
+		path = u.Path
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+	if fi.IsDir() {
+		fh.Close()
+		return nil, fmt.Errorf("is a directory: %s", path)
+	}
+
+	if !modTime.IsZero() && !fi.ModTime().After(modTime) {
+		fh.Close()
+		return &Response{Size: -1, ModTime: modTime, NotModified: true}, nil
+	}
+
+	return &Response{Body: fh, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+// SchemeFetcher: Fetcher using the Fetcher for the URL scheme,
+// the "" scheme is used for plain filesystem paths.
+type SchemeFetcher map[string]Fetcher
+
+func urlScheme(url string) string {
+	if i := strings.Index(url, "://"); i > 0 {
+		return strings.ToLower(url[:i])
+	}
+	return ""
+}
+
+func (sf SchemeFetcher) Fetch(ctx context.Context, url string) (*Response, error) {
+	scheme := urlScheme(url)
+	f, ok := sf[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported scheme (%s): %s", scheme, url)
+	}
+	return f.Fetch(ctx, url)
+}
+
+// FetchIf: Using the Fetcher for the URL scheme, which does a plain Fetch if
+// it isn't a ConditionalFetcher.
+func (sf SchemeFetcher) FetchIf(ctx context.Context, url string, etag string,
+	modTime time.Time) (*Response, error) {
+	scheme := urlScheme(url)
+	f, ok := sf[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported scheme (%s): %s", scheme, url)
+	}
+	if cf, ok := f.(ConditionalFetcher); ok {
+		return cf.FetchIf(ctx, url, etag, modTime)
+	}
+	return f.Fetch(ctx, url)
+}
+
+// DefaultFetcher: Used when a Snapshot or Repodata has no Fetcher.
+var DefaultFetcher Fetcher = SchemeFetcher{
+	"http":  &HTTPFetcher{},
+	"https": &HTTPFetcher{},
+	"file":  FileFetcher{},
+	"":      FileFetcher{},
+}
+
+func fetcher(f Fetcher) Fetcher {
+	if f == nil {
+		return DefaultFetcher
+	}
+	return f
+}
+
+func url2bytes(ctx context.Context, f Fetcher, url string) ([]byte, error) {
+	resp, err := fetcher(f).Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bbody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return bbody, nil
+}
@@ -0,0 +1,74 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// PkgFile: An entry in filelists.xml.
+type PkgFile struct {
+	Path string
+	Type string // "" for a file, "dir" or "ghost"
+}
+
+type xmlFilelist struct {
+	PkgID string `xml:"pkgid,attr"`
+	Files []struct {
+		Type string `xml:"type,attr"`
+		Path string `xml:",chardata"`
+	} `xml:"file"`
+}
+
+// parseFilelists: Calls fn with the pkgid and files of each package.
+func parseFilelists(r io.Reader, fn func(string, []PkgFile) error) error {
+	var xf xmlFilelist
+	return xmlEach(r, "package", &xf, func() error {
+		files := make([]PkgFile, 0, len(xf.Files))
+		for _, f := range xf.Files {
+			files = append(files, PkgFile{Path: f.Path, Type: f.Type})
+		}
+		err := fn(xf.PkgID, files)
+		xf = xmlFilelist{}
+		return err
+	})
+}
+
+// Files: All of the files in the package, from filelists.xml. Empty until
+// Pkgs.LoadFiles is called.
+func (pkg *Pkg) Files() []PkgFile {
+	return pkg.filelist
+}
+
+func (pkgs *Pkgs) LoadFiles() error {
+	return pkgs.LoadFilesContext(context.Background())
+}
+
+// LoadFilesContext: Download filelists.xml, for the Repo, and give each of
+// the packages, by pkgid, its Files. The packages all have to be from the
+// Repo, and queries then use all the files instead of just those in
+// primary.xml. The packages are shared with any Pkgs made from these, by
+// Filter, Latest, Match, Merge etc., so call this before making or querying
+// those. Queries on these Pkgs can run at the same time.
+func (pkgs *Pkgs) LoadFilesContext(ctx context.Context) error {
+	repo := pkgs.Repo
+	if repo == nil || repo.Files.Path == "" {
+		return errors.New("no filelists for the packages")
+	}
+
+	return pkgs.loadByPkgID(ctx, repo.Files,
+		func(r io.Reader, add func(string, func(*Pkg))) error {
+			return parseFilelists(r, func(pkgid string, files []PkgFile) error {
+				add(pkgid, func(p *Pkg) { p.filelist = files })
+				return nil
+			})
+		}, func(p *Pkg) { p.filelist = nil })
+}
+
+// WhatOwns: The packages with the file, or dir, like rpm -qf. Without
+// LoadFiles only the files in primary.xml are known.
+func (pkgs *Pkgs) WhatOwns(path string) *Pkgs {
+	ret := &Pkgs{Repo: pkgs.Repo}
+	ret.Pkgs = append(ret.Pkgs, pkgs.index().files[path]...)
+	return ret
+}
@@ -0,0 +1,89 @@
+package repos
+
+import (
+	"os"
+	"testing"
+)
+
+const tFILELISTS = `<?xml version="1.0" encoding="UTF-8"?>
+<filelists xmlns="http://linux.duke.edu/metadata/filelists" packages="2">
+<package pkgid="abcd" name="foo" arch="noarch">
+  <version epoch="0" ver="1.0" rel="1"/>
+  <file>/usr/bin/foo</file>
+  <file>/usr/share/foo/data</file>
+  <file type="dir">/usr/share/foo</file>
+  <file type="ghost">/var/log/foo.log</file>
+</package>
+<package pkgid="ef01" name="bar" arch="x86_64">
+  <version epoch="1" ver="2.0" rel="1"/>
+  <file type="dir">/usr/share/foo</file>
+</package>
+</filelists>
+`
+
+func TestLoadFiles(t *testing.T) {
+	dir := tREPODATA(t, map[string]string{"filelists": tFILELISTS})
+	defer os.RemoveAll(dir)
+
+	pkgs := tLOAD(t, dir)
+	if n := len(pkgs.WhatOwns("/usr/share/foo/data").Pkgs); n != 0 {
+		t.Errorf("before LoadFiles: got %d owners\n", n)
+	}
+	if n := len(pkgs.WhatOwns("/usr/bin/foo").Pkgs); n != 1 {
+		t.Errorf("before LoadFiles, primary: got %d owners\n", n)
+	}
+
+	if err := pkgs.LoadFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		path string
+		num  int
+	}{
+		{"/usr/bin/foo", 1},
+		{"/usr/share/foo/data", 1},
+		{"/usr/share/foo", 2},
+		{"/var/log/foo.log", 1},
+		{"/usr/bin/bar", 0},
+	}
+	for i := range data {
+		if n := len(pkgs.WhatOwns(data[i].path).Pkgs); n != data[i].num {
+			t.Errorf("WhatOwns(%s): got %d, want %d\n", data[i].path, n,
+				data[i].num)
+		}
+	}
+
+	for _, p := range pkgs.Pkgs {
+		if p.name != "foo" {
+			continue
+		}
+		files := p.Files()
+		if len(files) != 4 || files[2].Type != "dir" || files[3].Type != "ghost" {
+			t.Errorf("Files: %v\n", files)
+		}
+	}
+}
+
+func TestLoadFilesQueries(t *testing.T) {
+	dir := tREPODATA(t, map[string]string{"filelists": tFILELISTS})
+	defer os.RemoveAll(dir)
+
+	pkgs := tLOAD(t, dir)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			pkgs.WhatOwns("/usr/share/foo")
+			pkgs.WhatProvidesDep(Dependency{Name: "/usr/bin/foo"})
+		}
+	}()
+	if err := pkgs.LoadFiles(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if n := len(pkgs.WhatOwns("/usr/share/foo").Pkgs); n != 2 {
+		t.Errorf("after LoadFiles: got %d owners\n", n)
+	}
+}
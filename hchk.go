@@ -5,45 +5,156 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"errors"
 	"fmt"
 	"hash"
 )
 
-func hchk(data []byte, chk Checksum) bool {
-	var hash hash.Hash
-	switch chk.Kind {
+var (
+	// ErrUnknownChecksumType: Matches any *ChecksumTypeError
+	ErrUnknownChecksumType = errors.New("unknown checksum type")
+	// ErrChecksumMismatch: Matches any *ChecksumError
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrDecompress: Matches any *DecompressError
+	ErrDecompress = errors.New("decompression failed")
+	// ErrNoRepoMD: Matches any *RepoMDError
+	ErrNoRepoMD = errors.New("no usable repomd.xml")
+)
+
+// ChecksumTypeError: The checksum type is empty or isn't supported.
+type ChecksumTypeError struct {
+	Kind string
+}
+
+func (e *ChecksumTypeError) Error() string {
+	if e.Kind == "" {
+		return "no checksum type specified"
+	}
+	return fmt.Sprintf("unknown checksum type <%s>", e.Kind)
+}
+
+func (e *ChecksumTypeError) Is(target error) bool {
+	return target == ErrUnknownChecksumType
+}
+
+// ChecksumError: The data downloaded for Path doesn't match the checksum.
+type ChecksumError struct {
+	Path     string
+	Expected Checksum
+	Actual   Checksum
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected %s, got %s",
+		e.Path, e.Expected, e.Actual)
+}
+
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+// DecompressError: The data downloaded for Path couldn't be decompressed.
+type DecompressError struct {
+	Path string
+	Err  error
+}
+
+func (e *DecompressError) Error() string {
+	return fmt.Sprintf("decompression failed for %s: %v", e.Path, e.Err)
+}
+
+func (e *DecompressError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DecompressError) Is(target error) bool {
+	return target == ErrDecompress
+}
+
+// RepoMDError: None of the URLs had a usable repomd.xml, Err is why the last
+// one didn't, if there was one.
+type RepoMDError struct {
+	URLs int
+	Err  error
+}
+
+func (e *RepoMDError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("no usable repomd.xml from %d URLs", e.URLs)
+	}
+	return fmt.Sprintf("no usable repomd.xml from %d URLs: %v", e.URLs, e.Err)
+}
+
+func (e *RepoMDError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RepoMDError) Is(target error) bool {
+	return target == ErrNoRepoMD
+}
+
+func newHash(kind string) (hash.Hash, error) {
+	switch kind {
 	case "md5":
-		hash = md5.New()
+		return md5.New(), nil
 	case "sha":
 		fallthrough
 	case "sha1":
-		hash = sha1.New()
+		return sha1.New(), nil
 	case "sha2":
 		fallthrough
 	case "sha256":
-		hash = sha256.New()
+		return sha256.New(), nil
 	case "sha512":
-		hash = sha512.New()
-	case "":
-		panic("No hash specified")
+		return sha512.New(), nil
 	default:
-		panic(fmt.Sprintf("Unknown hash <%s> specified", chk.Kind))
+		return nil, &ChecksumTypeError{Kind: kind}
 	}
+}
 
-	hash.Write(data)
-	hval := fmt.Sprintf("%x", hash.Sum(nil))
-	if hval != chk.Data {
-		// fmt.Println("JDBG:", chk.Kind, hval, chk.Data)
-	}
-	return hval == chk.Data
+// chksWriter: Hashes everything written to it, for each of the checksums.
+type chksWriter struct {
+	chks   []Checksum
+	hashes []hash.Hash
 }
 
-func hchks(data []byte, chks []Checksum) bool {
+func newChksWriter(chks []Checksum) (*chksWriter, error) {
+	cw := &chksWriter{chks: chks}
 	for _, chk := range chks {
-		if !hchk(data, chk) {
-			return false
+		hash, err := newHash(chk.Kind)
+		if err != nil {
+			return nil, err
 		}
+		cw.hashes = append(cw.hashes, hash)
+	}
+
+	return cw, nil
+}
+
+func (cw *chksWriter) Write(p []byte) (int, error) {
+	for _, hash := range cw.hashes {
+		hash.Write(p)
+	}
+	return len(p), nil
+}
+
+func (cw *chksWriter) verify(path string) error {
+	for i, chk := range cw.chks {
+		hval := fmt.Sprintf("%x", cw.hashes[i].Sum(nil))
+		if hval != chk.Data {
+			return &ChecksumError{Path: path, Expected: chk,
+				Actual: Checksum{Kind: chk.Kind, Data: hval}}
+		}
+	}
+	return nil
+}
+
+func hchks(path string, data []byte, chks []Checksum) error {
+	cw, err := newChksWriter(chks)
+	if err != nil {
+		return err
 	}
 
-	return true
+	cw.Write(data)
+	return cw.verify(path)
 }
@@ -0,0 +1,93 @@
+package repos
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksumErrors(t *testing.T) {
+	data := []byte("data")
+	good := Checksum{Kind: "sha256", Data: fmt.Sprintf("%x", sha256.Sum256(data))}
+	bad := Checksum{Kind: "sha256", Data: "abcd"}
+
+	if err := hchks("p", data, []Checksum{good}); err != nil {
+		t.Errorf("good: %v\n", err)
+	}
+
+	err := hchks("p", data, []Checksum{bad})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("bad: got %v, want ErrChecksumMismatch\n", err)
+	}
+	var cerr *ChecksumError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("bad: got %v, want a ChecksumError\n", err)
+	}
+	if cerr.Path != "p" || cerr.Expected != bad || cerr.Actual != good {
+		t.Errorf("ChecksumError: got %+v\n", cerr)
+	}
+
+	err = hchks("p", data, []Checksum{{Kind: "crc32", Data: "abcd"}})
+	var terr *ChecksumTypeError
+	if !errors.Is(err, ErrUnknownChecksumType) || !errors.As(err, &terr) ||
+		terr.Kind != "crc32" {
+		t.Errorf("crc32: got %v, want ErrUnknownChecksumType\n", err)
+	}
+	if errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("crc32: is ErrChecksumMismatch\n")
+	}
+}
+
+func TestDecompressError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repos-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Not gzip, but the checksum matches so it's not a bad download.
+	data := []byte("not gzip data")
+	if err := ioutil.WriteFile(filepath.Join(dir, "primary.xml.gz"), data,
+		0644); err != nil {
+		t.Fatal(err)
+	}
+	repo := &Repodata{Baseurl: dir + "/"}
+	repo.Primary.Path = "primary.xml.gz"
+	repo.Primary.Chks = []Checksum{{Kind: "sha256",
+		Data: fmt.Sprintf("%x", sha256.Sum256(data))}}
+
+	_, err = repo.Load()
+	var derr *DecompressError
+	if !errors.Is(err, ErrDecompress) || !errors.As(err, &derr) ||
+		derr.Path != "primary.xml.gz" || derr.Err == nil {
+		t.Errorf("got %v, want a DecompressError\n", err)
+	}
+}
+
+func TestEmptyRepoMD(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repos-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "repodata"), 0755)
+	if err := ioutil.WriteFile(filepath.Join(dir, "repodata", "repomd.xml"),
+		nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := Baseurl(dir)
+	repomd, err := snap.RepoMD()
+	if repomd != nil || !errors.Is(err, ErrNoRepoMD) {
+		t.Errorf("empty repomd.xml: got %v, %v\n", repomd, err)
+	}
+
+	repomd, err = (&Snapshot{}).RepoMD()
+	if repomd != nil || !errors.Is(err, ErrNoRepoMD) {
+		t.Errorf("no URLs: got %v, %v\n", repomd, err)
+	}
+}
@@ -1,36 +1,20 @@
 package repos
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"sort"
+	"strings"
 	"time"
 )
 
-func url2bytes(url string) ([]byte, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("non-200 status (%s): %s", url, resp.Status)
-		return nil, err
-	}
-
-	bbody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	return bbody, nil
-}
-
 type URL struct {
-	URL string
-	Pri int
+	URL            string
+	Pri            int
+	Protocol       string
+	Location       string // Country code of the mirror, if known
+	MaxConnections int    // 0 if unknown
 }
 
 type Checksum struct {
@@ -50,27 +34,129 @@ type Data struct {
 }
 
 type Snapshot struct {
-	URLs   []URL
-	Repomd Data
+	URLs       []URL
+	Repomd     Data
+	Alternates []Data // Older repomd.xml data that is still acceptable
+	Fetcher    Fetcher
+}
+
+// defProtocols: The mirror protocols used by MetalinkContext and
+// MirrorlistContext, most preferred first.
+func defProtocols() []string {
+	return []string{"https", "http"}
+}
+
+func urlProtocol(url string) string {
+	if scheme := urlScheme(url); scheme != "" {
+		return scheme
+	}
+	return "file"
+}
+
+// SortURLs: Keep only the URLs using one of the protocols, ordered by
+// preference then by the protocols order. Ties are ordered by URL, so the
+// same mirror is picked each time.
+func (snap *Snapshot) SortURLs(protocols []string) {
+	rank := make(map[string]int, len(protocols))
+	for i, proto := range protocols {
+		if _, ok := rank[proto]; !ok {
+			rank[proto] = i
+		}
+	}
+
+	var urls []URL
+	for _, u := range snap.URLs {
+		if _, ok := rank[u.Protocol]; ok {
+			urls = append(urls, u)
+		}
+	}
+
+	sort.SliceStable(urls, func(i, j int) bool {
+		if urls[i].Pri != urls[j].Pri {
+			return urls[i].Pri > urls[j].Pri
+		}
+		ri, rj := rank[urls[i].Protocol], rank[urls[j].Protocol]
+		if ri != rj {
+			return ri < rj
+		}
+		return urls[i].URL < urls[j].URL
+	})
+
+	snap.URLs = urls
+}
+
+// PreferLocation: Move the URLs for mirrors in any of the locations to the
+// front, keeping the current order otherwise.
+func (snap *Snapshot) PreferLocation(locations ...string) {
+	near := func(u URL) bool {
+		for _, loc := range locations {
+			if strings.EqualFold(u.Location, loc) {
+				return true
+			}
+		}
+		return false
+	}
+
+	sort.SliceStable(snap.URLs, func(i, j int) bool {
+		return near(snap.URLs[i]) && !near(snap.URLs[j])
+	})
 }
 
 func Metalink(url string) (*Snapshot, error) {
+	return MetalinkWith(nil, url)
+}
+
+// MetalinkWith: Metalink, using the Fetcher for all network reads.
+func MetalinkWith(f Fetcher, url string) (*Snapshot, error) {
+	return MetalinkContext(context.Background(), f, url)
+}
+
+// MetalinkContext: MetalinkWith, the download is cancelled with the ctx.
+// Only https and http mirrors are used, https first.
+func MetalinkContext(ctx context.Context, f Fetcher, url string) (*Snapshot, error) {
+	return MetalinkProtocols(ctx, f, url, defProtocols())
+}
+
+// MetalinkProtocols: MetalinkContext, only using the mirrors with one of the
+// protocols, most preferred first. Others like "file", "ftp" or "rsync" can
+// be used when the Fetcher supports them.
+func MetalinkProtocols(ctx context.Context, f Fetcher, url string,
+	protocols []string) (*Snapshot, error) {
+	type xmlHashes []struct {
+		T string `xml:"type,attr"`
+		D string `xml:",chardata"`
+	}
 	var xmlData struct {
-		Timestamp    int64 `xml:"files>file>timestamp"`
-		Size         int   `xml:"files>file>size"`
-		Verification []struct {
-			T string `xml:"type,attr"`
-			D string `xml:",chardata"`
-		} `xml:"files>file>verification>hash"`
-		Maxconnections int `xml:"maxconnections,attr"`
-		URLs           []struct {
-			URL        string `xml:",chardata"`
-			Preference int    `xml:"preference,attr"`
-			Protocol   string `xml:"protocol,attr"`
-		} `xml:"files>file>resources>url"`
+		Timestamp    int64     `xml:"files>file>timestamp"`
+		Size         int       `xml:"files>file>size"`
+		Verification xmlHashes `xml:"files>file>verification>hash"`
+		Alternates   []struct {
+			Timestamp    int64     `xml:"timestamp"`
+			Size         int       `xml:"size"`
+			Verification xmlHashes `xml:"verification>hash"`
+		} `xml:"files>file>alternates>alternate"`
+		Resources struct {
+			Maxconnections int `xml:"maxconnections,attr"`
+			URLs           []struct {
+				URL            string `xml:",chardata"`
+				Preference     int    `xml:"preference,attr"`
+				Protocol       string `xml:"protocol,attr"`
+				Type           string `xml:"type,attr"`
+				Location       string `xml:"location,attr"`
+				Maxconnections int    `xml:"maxconnections,attr"`
+			} `xml:"url"`
+		} `xml:"files>file>resources"`
+	}
+	mkData := func(timestamp int64, size int, hashes xmlHashes) Data {
+		d := Data{Path: "repodata/repomd.xml", Size: size,
+			TM: time.Unix(timestamp, 0)}
+		for _, v := range hashes {
+			d.Chks = append(d.Chks, Checksum{Kind: v.T, Data: v.D})
+		}
+		return d
 	}
 
-	metalink, err := url2bytes(url)
+	metalink, err := url2bytes(ctx, f, url)
 	if err != nil {
 		// fmt.Printf("error: %v", err)
 		return nil, err
@@ -83,37 +169,58 @@ func Metalink(url string) (*Snapshot, error) {
 		// fmt.Printf("error: %v", err)
 		return nil, err
 	}
-	if len(xmlData.URLs) < 1 {
+	if len(xmlData.Resources.URLs) < 1 {
 		err = fmt.Errorf("error: No data for metalink")
-		fmt.Println(string(metalink))
 		return nil, err
 	}
 
-	ret := &Snapshot{}
+	ret := &Snapshot{Fetcher: f}
 
-	ret.Repomd.Path = "repodata/repomd.xml"
-	ret.Repomd.Size = xmlData.Size
-	ret.Repomd.TM = time.Unix(xmlData.Timestamp, 0)
-	for i := range xmlData.Verification {
-		v := &xmlData.Verification[i]
-		ret.Repomd.Chks = append(ret.Repomd.Chks, Checksum{Kind: v.T, Data: v.D})
+	ret.Repomd = mkData(xmlData.Timestamp, xmlData.Size, xmlData.Verification)
+	for _, v := range xmlData.Alternates {
+		ret.Alternates = append(ret.Alternates,
+			mkData(v.Timestamp, v.Size, v.Verification))
 	}
-	for i := range xmlData.URLs {
-		v := &xmlData.URLs[i]
-		if v.Protocol != "http" {
-			continue
+	for i := range xmlData.Resources.URLs {
+		v := &xmlData.Resources.URLs[i]
+		u := URL{URL: strings.TrimSpace(v.URL), Pri: v.Preference,
+			Protocol: v.Protocol, Location: v.Location,
+			MaxConnections: v.Maxconnections}
+		if u.Protocol == "" {
+			u.Protocol = v.Type
+		}
+		if u.Protocol == "" {
+			u.Protocol = urlProtocol(u.URL)
 		}
-		ret.URLs = append(ret.URLs, URL{URL: v.URL, Pri: v.Preference})
+		if u.MaxConnections == 0 {
+			u.MaxConnections = xmlData.Resources.Maxconnections
+		}
+		ret.URLs = append(ret.URLs, u)
+	}
+	ret.SortURLs(protocols)
+	if len(ret.URLs) < 1 {
+		err = fmt.Errorf("error: No usable URLs in metalink")
+		return nil, err
 	}
 
 	return ret, nil
 }
 
 func Baseurl(url string) (*Snapshot, error) {
-	ret := &Snapshot{}
+	return BaseurlWith(nil, url)
+}
+
+// BaseurlWith: Baseurl, using the Fetcher for all network reads.
+// The url can also be a file:// URL or a path to a local repo.
+func BaseurlWith(f Fetcher, url string) (*Snapshot, error) {
+	ret := &Snapshot{Fetcher: f}
+	if !strings.HasSuffix(url, "/") {
+		url += "/"
+	}
 	path := "repodata/repomd.xml"
 	ret.Repomd.Path = path
-	ret.URLs = append(ret.URLs, URL{URL: url + path, Pri: 1})
+	ret.URLs = append(ret.URLs, URL{URL: url + path, Pri: 1,
+		Protocol: urlProtocol(url)})
 
 	return ret, nil
 }
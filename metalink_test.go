@@ -0,0 +1,141 @@
+package repos
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSortURLs(t *testing.T) {
+	snap := &Snapshot{URLs: []URL{
+		{URL: "http://b.example.com/", Pri: 100, Protocol: "http"},
+		{URL: "rsync://a.example.com/", Pri: 100, Protocol: "rsync"},
+		{URL: "https://z.example.com/", Pri: 99, Protocol: "https"},
+		{URL: "https://b.example.com/", Pri: 100, Protocol: "https"},
+		{URL: "https://a.example.com/", Pri: 100, Protocol: "https"},
+		{URL: "http://a.example.com/", Pri: 100, Protocol: "http"},
+	}}
+
+	// Preference, then protocol, then URL.
+	snap.SortURLs([]string{"https", "http"})
+	res := []string{"https://a.example.com/", "https://b.example.com/",
+		"http://a.example.com/", "http://b.example.com/",
+		"https://z.example.com/"}
+	if len(snap.URLs) != len(res) {
+		t.Fatalf("SortURLs: got %v\n", snap.URLs)
+	}
+	for i := range res {
+		if snap.URLs[i].URL != res[i] {
+			t.Errorf("SortURLs %d\n  res = %s\n  ret = %s\n", i, res[i],
+				snap.URLs[i].URL)
+		}
+	}
+
+	snap.SortURLs([]string{"http"})
+	if len(snap.URLs) != 2 || snap.URLs[0].URL != "http://a.example.com/" {
+		t.Errorf("SortURLs(http): got %v\n", snap.URLs)
+	}
+}
+
+const tMETALINK = `<?xml version="1.0" encoding="utf-8"?>
+<metalink version="3.0" xmlns="http://www.metalinker.org/" type="dynamic" pubdate="Mon, 01 Jan 2018 00:00:00 GMT" generator="mirrormanager" xmlns:mm0="http://fedorahosted.org/mirrormanager">
+ <files>
+  <file name="repomd.xml">
+   <mm0:timestamp>1525000000</mm0:timestamp>
+   <size>3</size>
+   <verification>
+     <hash type="md5">%x</hash>
+     <hash type="sha256">%x</hash>
+   </verification>
+   <mm0:alternates>
+     <mm0:alternate>
+       <mm0:timestamp>1524000000</mm0:timestamp>
+       <size>3</size>
+       <verification>
+         <hash type="sha256">%x</hash>
+       </verification>
+     </mm0:alternate>
+   </mm0:alternates>
+   <resources maxconnections="1">
+    <url protocol="http" type="http" location="US" preference="100">http://b.example/repodata/repomd.xml</url>
+    <url protocol="https" type="https" location="US" preference="100">https://a.example/repodata/repomd.xml</url>
+    <url protocol="rsync" type="rsync" location="DE" preference="100">rsync://c.example/repodata/repomd.xml</url>
+    <url protocol="https" type="https" location="DE" preference="99" maxconnections="5">https://d.example/repodata/repomd.xml</url>
+   </resources>
+  </file>
+ </files>
+</metalink>
+`
+
+func TestMetalink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repos-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	metalink := fmt.Sprintf(tMETALINK, md5.Sum([]byte("new")),
+		sha256.Sum256([]byte("new")), sha256.Sum256([]byte("old")))
+	fname := filepath.Join(dir, "metalink")
+	if err := ioutil.WriteFile(fname, []byte(metalink), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := Metalink(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if snap.Repomd.Path != "repodata/repomd.xml" || snap.Repomd.Size != 3 ||
+		snap.Repomd.TM.Unix() != 1525000000 || len(snap.Repomd.Chks) != 2 ||
+		snap.Repomd.Chks[0].Kind != "md5" {
+		t.Errorf("Repomd: got %+v\n", snap.Repomd)
+	}
+	if len(snap.Alternates) != 1 || snap.Alternates[0].TM.Unix() != 1524000000 ||
+		len(snap.Alternates[0].Chks) != 1 {
+		t.Errorf("Alternates: got %+v\n", snap.Alternates)
+	}
+
+	res := []URL{
+		{"https://a.example/repodata/repomd.xml", 100, "https", "US", 1},
+		{"http://b.example/repodata/repomd.xml", 100, "http", "US", 1},
+		{"https://d.example/repodata/repomd.xml", 99, "https", "DE", 5},
+	}
+	if len(snap.URLs) != len(res) {
+		t.Fatalf("URLs: got %v\n", snap.URLs)
+	}
+	for i := range res {
+		if snap.URLs[i] != res[i] {
+			t.Errorf("URLs %d\n  res = %+v\n  ret = %+v\n", i, res[i],
+				snap.URLs[i])
+		}
+	}
+
+	snap.PreferLocation("de")
+	if snap.URLs[0].URL != "https://d.example/repodata/repomd.xml" {
+		t.Errorf("PreferLocation: got %v\n", snap.URLs)
+	}
+
+	// The current repomd.xml, or an alternate, but nothing else.
+	if err := snap.repomdOK([]byte("new")); err != nil {
+		t.Errorf("repomdOK(new): %v\n", err)
+	}
+	if err := snap.repomdOK([]byte("old")); err != nil {
+		t.Errorf("repomdOK(old): %v\n", err)
+	}
+	if err := snap.repomdOK([]byte("bad")); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("repomdOK(bad): got %v\n", err)
+	}
+
+	snap, err = MetalinkProtocols(context.Background(), nil, fname,
+		[]string{"rsync"})
+	if err != nil || len(snap.URLs) != 1 || snap.URLs[0].Protocol != "rsync" {
+		t.Errorf("MetalinkProtocols(rsync): got %v, %v\n", snap, err)
+	}
+}
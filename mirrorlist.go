@@ -0,0 +1,95 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	neturl "net/url"
+	"strings"
+)
+
+// mirrorlistVars: The yum variables the mirrorlist query sets, so they can be
+// expanded in the returned baseurls.
+func mirrorlistVars(url string) map[string]string {
+	vars := make(map[string]string)
+
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return vars
+	}
+	q := u.Query()
+	if arch := q.Get("arch"); arch != "" {
+		vars["basearch"] = arch
+		vars["arch"] = arch
+	}
+	if release := q.Get("release"); release != "" {
+		vars["releasever"] = release
+	}
+
+	return vars
+}
+
+func expandVars(s string, vars map[string]string) string {
+	for k, v := range vars {
+		s = strings.Replace(s, "${"+k+"}", v, -1)
+		s = strings.Replace(s, "$"+k, v, -1)
+	}
+	return s
+}
+
+func Mirrorlist(url string) (*Snapshot, error) {
+	return MirrorlistWith(nil, url)
+}
+
+// MirrorlistWith: Mirrorlist, using the Fetcher for all network reads.
+func MirrorlistWith(f Fetcher, url string) (*Snapshot, error) {
+	return MirrorlistContext(context.Background(), f, url)
+}
+
+// MirrorlistContext: Create a Snapshot from a plain text list of baseurls,
+// one per line and most preferred first. Any $basearch, $arch and
+// $releasever in the baseurls are taken from the arch and release of the
+// mirrorlist url query. Only https and http mirrors are used.
+func MirrorlistContext(ctx context.Context, f Fetcher, url string) (*Snapshot, error) {
+	return MirrorlistProtocols(ctx, f, url, defProtocols())
+}
+
+// MirrorlistProtocols: MirrorlistContext, only using the mirrors with one of
+// the protocols, see MetalinkProtocols.
+func MirrorlistProtocols(ctx context.Context, f Fetcher, url string,
+	protocols []string) (*Snapshot, error) {
+	mirrorlist, err := url2bytes(ctx, f, url)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := mirrorlistVars(url)
+
+	var baseurls []string
+	for _, line := range strings.Split(string(mirrorlist), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = expandVars(line, vars)
+		if !strings.HasSuffix(line, "/") {
+			line += "/"
+		}
+		baseurls = append(baseurls, line)
+	}
+
+	ret := &Snapshot{Fetcher: f}
+	path := "repodata/repomd.xml"
+	ret.Repomd.Path = path
+	for i, baseurl := range baseurls {
+		ret.URLs = append(ret.URLs, URL{URL: baseurl + path,
+			Pri: len(baseurls) - i, Protocol: urlProtocol(baseurl)})
+	}
+	ret.SortURLs(protocols)
+	if len(ret.URLs) < 1 {
+		err = fmt.Errorf("error: No usable URLs in mirrorlist")
+		return nil, err
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,63 @@
+package repos
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const tMIRRORLIST = `# repo = base arch = x86_64 country = US
+http://a.example/centos/$releasever/os/$basearch/
+
+https://b.example/centos/${releasever}/os/${basearch}
+  # indented comment
+not a url
+ftp://c.example/centos/$releasever/os/$arch/
+http://d.example/centos/$releasever/os/$arch/
+`
+
+func TestMirrorlist(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		w.Write([]byte(tMIRRORLIST))
+	}))
+	defer srv.Close()
+
+	url := srv.URL + "/?release=7&arch=x86_64&repo=os"
+	snap, err := Mirrorlist(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := []URL{
+		{URL: "http://a.example/centos/7/os/x86_64/repodata/repomd.xml",
+			Pri: 5, Protocol: "http"},
+		{URL: "https://b.example/centos/7/os/x86_64/repodata/repomd.xml",
+			Pri: 4, Protocol: "https"},
+		{URL: "http://d.example/centos/7/os/x86_64/repodata/repomd.xml",
+			Pri: 1, Protocol: "http"},
+	}
+	if len(snap.URLs) != len(res) {
+		t.Fatalf("URLs: got %v\n", snap.URLs)
+	}
+	for i := range res {
+		if snap.URLs[i] != res[i] {
+			t.Errorf("URLs %d\n  res = %+v\n  ret = %+v\n", i, res[i],
+				snap.URLs[i])
+		}
+	}
+
+	snap, err = MirrorlistProtocols(context.Background(), nil, url,
+		[]string{"ftp"})
+	if err != nil || len(snap.URLs) != 1 ||
+		snap.URLs[0].URL != "ftp://c.example/centos/7/os/x86_64/repodata/repomd.xml" {
+		t.Errorf("MirrorlistProtocols(ftp): got %v, %v\n", snap, err)
+	}
+
+	// Without the query there's nothing to expand.
+	snap, err = Mirrorlist(srv.URL + "/")
+	if err != nil || snap.URLs[0].URL != "http://a.example/centos/$releasever/os/$basearch/repodata/repomd.xml" {
+		t.Errorf("Mirrorlist(no query): got %v, %v\n", snap, err)
+	}
+}
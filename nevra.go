@@ -0,0 +1,209 @@
+package repos
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NEVRA: A package identifier, as parsed by ParseNEVRA. Empty Version,
+// Release or Arch mean they weren't given.
+type NEVRA struct {
+	Name     string
+	Epoch    int
+	Version  string
+	Release  string
+	Arch     string
+	HasEpoch bool // Epoch was given, even if it's 0
+
+	// Ambiguous: The string could also be read another way, Eg. as a name
+	// with dashes in it, or with an arch this package doesn't know.
+	Ambiguous bool
+}
+
+func knownArch(arch string) bool {
+	switch arch {
+	case "noarch", "src", "nosrc":
+		return true
+	}
+	for _, arches := range archCompat {
+		for _, a := range arches {
+			if a == arch {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func startsDigit(s string) bool {
+	return len(s) > 0 && s[0] >= '0' && s[0] <= '9'
+}
+
+// ParseNEVRA: Parse any of the forms of Pkg.Nevra, Nvra, Nevr, Nvr, Na,
+// Envra and Name, or an rpm filename like foo-1.2-3.fc28.x86_64.rpm or
+// foo-1.2-3.fc28.src.rpm. Filenames are never Ambiguous.
+func ParseNEVRA(s string) (NEVRA, error) {
+	var ret NEVRA
+
+	orig := s
+	filename := strings.HasSuffix(s, ".rpm")
+	if filename {
+		s = strings.TrimSuffix(s, ".rpm")
+	}
+
+	// Envra, epoch first...
+	if i := strings.IndexByte(s, ':'); i != -1 && !strings.Contains(s[:i], "-") {
+		epoch, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return ret, fmt.Errorf("bad epoch in NEVRA: %s", orig)
+		}
+		ret.Epoch = epoch
+		ret.HasEpoch = true
+		s = s[i+1:]
+	}
+
+	if i := strings.LastIndexByte(s, '.'); i != -1 {
+		if arch := s[i+1:]; filename || knownArch(arch) {
+			ret.Arch = arch
+			s = s[:i]
+		}
+	}
+
+	r := strings.LastIndexByte(s, '-')
+	v := -1
+	if r != -1 {
+		v = strings.LastIndexByte(s[:r], '-')
+	}
+	if v == -1 {
+		if filename {
+			return ret, fmt.Errorf("no version and release in rpm: %s", orig)
+		}
+		if ret.HasEpoch {
+			return ret, fmt.Errorf("epoch without version in NEVRA: %s", orig)
+		}
+		ret.Name = s
+		ret.Ambiguous = r != -1 && startsDigit(s[r+1:])
+		if ret.Name == "" {
+			return ret, fmt.Errorf("no name in NEVRA: %s", orig)
+		}
+		return ret, nil
+	}
+
+	ret.Name = s[:v]
+	ret.Version = s[v+1 : r]
+	ret.Release = s[r+1:]
+
+	// Nevra, epoch in the version...
+	if i := strings.IndexByte(ret.Version, ':'); i != -1 {
+		if ret.HasEpoch {
+			return ret, fmt.Errorf("two epochs in NEVRA: %s", orig)
+		}
+		epoch, err := strconv.Atoi(ret.Version[:i])
+		if err != nil {
+			return ret, fmt.Errorf("bad epoch in NEVRA: %s", orig)
+		}
+		ret.Epoch = epoch
+		ret.HasEpoch = true
+		ret.Version = ret.Version[i+1:]
+	}
+
+	if ret.Name == "" || ret.Version == "" || ret.Release == "" ||
+		strings.ContainsRune(ret.Version, ':') {
+		return ret, fmt.Errorf("bad NEVRA: %s", orig)
+	}
+
+	if !filename && !ret.HasEpoch && !startsDigit(ret.Version) {
+		ret.Ambiguous = true
+	}
+	if !filename && ret.Arch == "" && strings.Contains(ret.Release, ".") {
+		ret.Ambiguous = true
+	}
+
+	return ret, nil
+}
+
+func (n NEVRA) EVR() EVR {
+	return EVR{Epoch: n.Epoch, Version: n.Version, Release: n.Release}
+}
+
+func (n NEVRA) String() string {
+	ret := n.Name
+	if n.Version != "" {
+		ret += "-"
+		if n.HasEpoch && n.Epoch != 0 {
+			ret += fmt.Sprintf("%d:", n.Epoch)
+		}
+		ret += n.Version + "-" + n.Release
+	}
+	if n.Arch != "" {
+		ret += "." + n.Arch
+	}
+	return ret
+}
+
+// Match: Does the package have all the parts of the NEVRA that were given.
+func (n NEVRA) Match(pkg *Pkg) bool {
+	if n.Name != pkg.name {
+		return false
+	}
+	if n.HasEpoch && n.Epoch != pkg.epoch {
+		return false
+	}
+	if n.Version != "" && n.Version != pkg.version {
+		return false
+	}
+	if n.Release != "" && n.Release != pkg.release {
+		return false
+	}
+	if n.Arch != "" && n.Arch != pkg.arch {
+		return false
+	}
+	return true
+}
+
+// MatchSpec: The packages matching any of the specs, like dnf. Rpm filenames
+// (in any directory) and NEVRAs that aren't Ambiguous are looked up exactly,
+// anything else is matched as a glob, see Pkgs.Match.
+func (pkgs *Pkgs) MatchSpec(specs ...string) *Pkgs {
+	ret := &Pkgs{Repo: pkgs.Repo}
+	for _, spec := range specs {
+		ret = ret.Merge(pkgs.matchSpec(spec))
+	}
+	return ret
+}
+
+func (pkgs *Pkgs) matchSpec(spec string) *Pkgs {
+	if strings.ContainsAny(spec, "*?[") {
+		return pkgs.Match(spec)
+	}
+
+	s := spec
+	if strings.HasSuffix(s, ".rpm") {
+		s = filepath.Base(s)
+	}
+	n, err := ParseNEVRA(s)
+	if err != nil || n.Ambiguous {
+		return pkgs.Match(spec)
+	}
+	return pkgs.MatchNEVRA(n)
+}
+
+// MatchNEVRA: The packages matching the NEVRA exactly, see NEVRA.Match
+func (pkgs *Pkgs) MatchNEVRA(n NEVRA) *Pkgs {
+	ret := &Pkgs{Repo: pkgs.Repo}
+
+	// Pkgs are sorted by name first.
+	i := sort.Search(len(pkgs.Pkgs), func(i int) bool {
+		return pkgs.Pkgs[i].name >= n.Name
+	})
+	for ; i < len(pkgs.Pkgs) && pkgs.Pkgs[i].name == n.Name; i++ {
+		if n.Match(pkgs.Pkgs[i]) {
+			ret.Pkgs = append(ret.Pkgs, pkgs.Pkgs[i])
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,118 @@
+package repos
+
+import (
+	"testing"
+)
+
+func TestParseNEVRA(t *testing.T) {
+	data := []struct {
+		s string
+		n NEVRA
+	}{
+		{"foo", NEVRA{Name: "foo"}},
+		{"foo.x86_64", NEVRA{Name: "foo", Arch: "x86_64"}},
+		{"foo-bar", NEVRA{Name: "foo-bar"}},
+		{"foo-1.2", NEVRA{Name: "foo-1.2", Ambiguous: true}},
+		{"foo-1.2-3.fc28", NEVRA{Name: "foo", Version: "1.2",
+			Release: "3.fc28", Ambiguous: true}},
+		{"foo-1.2-3.fc28.x86_64", NEVRA{Name: "foo", Version: "1.2",
+			Release: "3.fc28", Arch: "x86_64"}},
+		{"foo-1.2-3", NEVRA{Name: "foo", Version: "1.2", Release: "3"}},
+		{"foo-1:1.2-3.fc28.i686", NEVRA{Name: "foo", Epoch: 1, HasEpoch: true,
+			Version: "1.2", Release: "3.fc28", Arch: "i686"}},
+		{"0:foo-1.2-3.fc28.noarch", NEVRA{Name: "foo", HasEpoch: true,
+			Version: "1.2", Release: "3.fc28", Arch: "noarch"}},
+		{"perl-Test-Simple", NEVRA{Name: "perl", Version: "Test",
+			Release: "Simple", Ambiguous: true}},
+		{"foo-bar-1.2-3.fc28.x86_64.rpm", NEVRA{Name: "foo-bar", Version: "1.2",
+			Release: "3.fc28", Arch: "x86_64"}},
+		{"foo-1.2-3.fc28.src.rpm", NEVRA{Name: "foo", Version: "1.2",
+			Release: "3.fc28", Arch: "src"}},
+		{"foo-1.2-3.el7.newarch.rpm", NEVRA{Name: "foo", Version: "1.2",
+			Release: "3.el7", Arch: "newarch"}},
+	}
+
+	for i := range data {
+		n, err := ParseNEVRA(data[i].s)
+		if err != nil {
+			t.Errorf("ParseNEVRA(%s): %v\n", data[i].s, err)
+			continue
+		}
+		if n != data[i].n {
+			t.Errorf("ParseNEVRA(%s)\n  res = %+v\n  ret = %+v\n",
+				data[i].s, data[i].n, n)
+		}
+	}
+
+	for _, s := range []string{"", "foo.rpm", "x:foo-1-2", "1:foo", "-1-2",
+		"foo-1:1:2-3"} {
+		if n, err := ParseNEVRA(s); err == nil {
+			t.Errorf("ParseNEVRA(%s) didn't fail: %+v\n", s, n)
+		}
+	}
+}
+
+func TestNEVRAMatch(t *testing.T) {
+	pkgs := &Pkgs{Pkgs: []*Pkg{
+		{name: "bar", version: "1", release: "1", arch: "x86_64"},
+		{name: "foo", epoch: 1, version: "1.2", release: "3", arch: "i686"},
+		{name: "foo", epoch: 1, version: "1.2", release: "3", arch: "x86_64"},
+		{name: "foo", epoch: 1, version: "1.3", release: "1", arch: "x86_64"},
+		{name: "foo-devel", epoch: 1, version: "1.2", release: "3", arch: "x86_64"},
+	}}
+
+	data := []struct {
+		s   string
+		num int
+	}{
+		{"foo", 3},
+		{"foo.x86_64", 2},
+		{"foo-1.2-3", 2},
+		{"foo-0:1.2-3", 0},
+		{"1:foo-1.2-3.i686", 1},
+		{"foo-devel-1.2-3.x86_64.rpm", 1},
+		{"baz", 0},
+	}
+	for i := range data {
+		n, err := ParseNEVRA(data[i].s)
+		if err != nil {
+			t.Errorf("ParseNEVRA(%s): %v\n", data[i].s, err)
+			continue
+		}
+		if num := len(pkgs.MatchNEVRA(n).Pkgs); num != data[i].num {
+			t.Errorf("MatchNEVRA(%s)\n  res = %d\n  ret = %d\n",
+				data[i].s, data[i].num, num)
+		}
+	}
+}
+
+func TestMatchSpec(t *testing.T) {
+	pkgs := &Pkgs{Pkgs: []*Pkg{
+		{name: "bar", version: "1", release: "1", arch: "x86_64"},
+		{name: "foo", epoch: 1, version: "1.2", release: "3", arch: "i686"},
+		{name: "foo", epoch: 1, version: "1.2", release: "3", arch: "x86_64"},
+		{name: "foo", epoch: 1, version: "1.3", release: "1", arch: "x86_64"},
+		{name: "foo-devel", epoch: 1, version: "1.2", release: "3", arch: "x86_64"},
+	}}
+
+	data := []struct {
+		specs []string
+		num   int
+	}{
+		{[]string{"foo"}, 3},
+		{[]string{"foo*"}, 4},
+		{[]string{"foo-1.2-3.x86_64"}, 1},
+		{[]string{"foo-devel-1.2-3.x86_64.rpm"}, 1},
+		{[]string{"/tmp/x/foo-devel-1.2-3.x86_64.rpm"}, 1},
+		{[]string{"../x/foo-1.2-3.i686.rpm"}, 1},
+		{[]string{"foo.x86_64", "foo-1.2-3"}, 3},
+		{[]string{"bar", "baz"}, 1},
+		{nil, 0},
+	}
+	for i := range data {
+		if num := len(pkgs.MatchSpec(data[i].specs...).Pkgs); num != data[i].num {
+			t.Errorf("MatchSpec(%v)\n  res = %d\n  ret = %d\n",
+				data[i].specs, data[i].num, num)
+		}
+	}
+}
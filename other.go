@@ -0,0 +1,104 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"time"
+)
+
+// Changelog: An entry in the changelog of a package, from other.xml.
+type Changelog struct {
+	Author string
+	Date   time.Time
+	Text   string
+}
+
+type xmlOther struct {
+	PkgID      string `xml:"pkgid,attr"`
+	Changelogs []struct {
+		Author string `xml:"author,attr"`
+		Date   int64  `xml:"date,attr"`
+		Text   string `xml:",chardata"`
+	} `xml:"changelog"`
+}
+
+// parseOther: Calls fn with the pkgid and changelogs of each package.
+func parseOther(r io.Reader, fn func(string, []Changelog) error) error {
+	var xo xmlOther
+	return xmlEach(r, "package", &xo, func() error {
+		cls := make([]Changelog, 0, len(xo.Changelogs))
+		for _, c := range xo.Changelogs {
+			cls = append(cls, Changelog{Author: c.Author,
+				Date: time.Unix(c.Date, 0), Text: c.Text})
+		}
+		err := fn(xo.PkgID, cls)
+		xo = xmlOther{}
+		return err
+	})
+}
+
+// Changelogs: The changelog of the package, in the order of other.xml.
+// Empty until Pkgs.LoadChangelogs is called.
+func (pkg *Pkg) Changelogs() []Changelog {
+	return pkg.changelogs
+}
+
+// ChangelogsSince: The changelog entries after the date, Eg. the newest
+// entry of the version being updated from.
+func (pkg *Pkg) ChangelogsSince(date time.Time) []Changelog {
+	var ret []Changelog
+	for _, c := range pkg.changelogs {
+		if c.Date.After(date) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// SearchChangelogs: The changelog entries with text matching the regexp,
+// Eg. CVE-[0-9]+-[0-9]+
+func (pkg *Pkg) SearchChangelogs(re *regexp.Regexp) []Changelog {
+	var ret []Changelog
+	for _, c := range pkg.changelogs {
+		if re.MatchString(c.Text) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// SearchChangelogs: The packages with a changelog entry matching the regexp.
+func (pkgs *Pkgs) SearchChangelogs(re *regexp.Regexp) *Pkgs {
+	ret := &Pkgs{Repo: pkgs.Repo}
+	for _, p := range pkgs.Pkgs {
+		if len(p.SearchChangelogs(re)) > 0 {
+			ret.Pkgs = append(ret.Pkgs, p)
+		}
+	}
+	return ret
+}
+
+func (pkgs *Pkgs) LoadChangelogs() error {
+	return pkgs.LoadChangelogsContext(context.Background())
+}
+
+// LoadChangelogsContext: Download other.xml, for the Repo, and give each of
+// the packages, by pkgid, its Changelogs. The packages all have to be from
+// the Repo, and as for LoadFilesContext this has to finish before any Pkgs
+// made from these are used.
+func (pkgs *Pkgs) LoadChangelogsContext(ctx context.Context) error {
+	repo := pkgs.Repo
+	if repo == nil || repo.Other.Path == "" {
+		return errors.New("no changelogs for the packages")
+	}
+
+	return pkgs.loadByPkgID(ctx, repo.Other,
+		func(r io.Reader, add func(string, func(*Pkg))) error {
+			return parseOther(r, func(pkgid string, cls []Changelog) error {
+				add(pkgid, func(p *Pkg) { p.changelogs = cls })
+				return nil
+			})
+		}, func(p *Pkg) { p.changelogs = nil })
+}
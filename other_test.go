@@ -0,0 +1,48 @@
+package repos
+
+import (
+	"os"
+	"regexp"
+	"testing"
+	"time"
+)
+
+const tOTHER = `<?xml version="1.0" encoding="UTF-8"?>
+<otherdata xmlns="http://linux.duke.edu/metadata/other" packages="2">
+<package pkgid="abcd" name="foo" arch="noarch">
+  <version epoch="0" ver="1.0" rel="1"/>
+  <changelog author="A Packager &lt;ap@example.com&gt; - 0.9-1" date="1500000000">- Update to 0.9</changelog>
+  <changelog author="A Packager &lt;ap@example.com&gt; - 1.0-1" date="1510000000">- Update to 1.0
+- Fix CVE-2017-1234</changelog>
+</package>
+<package pkgid="ef01" name="bar" arch="x86_64">
+  <version epoch="1" ver="2.0" rel="1"/>
+</package>
+</otherdata>
+`
+
+func TestLoadChangelogs(t *testing.T) {
+	dir := tREPODATA(t, map[string]string{"other": tOTHER})
+	defer os.RemoveAll(dir)
+
+	pkgs := tLOAD(t, dir)
+	if err := pkgs.LoadChangelogs(); err != nil {
+		t.Fatal(err)
+	}
+
+	re := regexp.MustCompile(`CVE-[0-9]+-[0-9]+`)
+	cves := pkgs.SearchChangelogs(re)
+	if len(cves.Pkgs) != 1 || cves.Pkgs[0].name != "foo" {
+		t.Fatalf("SearchChangelogs: got %v\n", cves.Pkgs)
+	}
+
+	foo := cves.Pkgs[0]
+	cls := foo.Changelogs()
+	if len(cls) != 2 || cls[0].Author != "A Packager <ap@example.com> - 0.9-1" {
+		t.Errorf("Changelogs: got %v\n", cls)
+	}
+	since := foo.ChangelogsSince(time.Unix(1500000000, 0))
+	if len(since) != 1 || since[0].Date.Unix() != 1510000000 {
+		t.Errorf("ChangelogsSince: got %v\n", since)
+	}
+}
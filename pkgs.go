@@ -1,15 +1,16 @@
 package repos
 
 import (
-	"bytes"
+	"context"
 	"crypto/md5"
 	"hash"
 
-	"encoding/xml"
 	"fmt"
 	"io"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"path/filepath"
 )
@@ -21,6 +22,39 @@ type Pkg struct {
 	release string
 	arch    string
 	chk     Checksum
+
+	summary       string
+	description   string
+	url           string
+	license       string
+	vendor        string
+	group         string
+	packager      string
+	buildhost     string
+	sourcerpm     string
+	buildTime     int64
+	fileTime      int64
+	packageSize   int64
+	installedSize int64
+	archiveSize   int64
+	locationBase  string
+	location      string
+	hdrStart      int64
+	hdrEnd        int64
+
+	provides    []Dependency
+	requires    []Dependency
+	conflicts   []Dependency
+	obsoletes   []Dependency
+	recommends  []Dependency
+	suggests    []Dependency
+	supplements []Dependency
+	enhances    []Dependency
+
+	files    []string  // Only the ones in primary.xml, used for file requires
+	filelist []PkgFile // All of them, from filelists.xml
+
+	changelogs []Changelog
 }
 
 func (pkg *Pkg) Nevra() string {
@@ -46,6 +80,10 @@ func (pkg *Pkg) Name() string {
 	return pkg.name
 }
 
+func (pkg *Pkg) EVR() EVR {
+	return EVR{Epoch: pkg.epoch, Version: pkg.version, Release: pkg.release}
+}
+
 func (pkg *Pkg) Envra() string {
 	return fmt.Sprintf("%d:%s-%s-%s.%s", pkg.epoch, pkg.name,
 		pkg.version, pkg.release, pkg.arch)
@@ -83,6 +121,70 @@ func (pkg *Pkg) Checksum() Checksum {
 	return pkg.chk
 }
 
+func (pkg *Pkg) Summary() string {
+	return pkg.summary
+}
+func (pkg *Pkg) Description() string {
+	return pkg.description
+}
+func (pkg *Pkg) URL() string {
+	return pkg.url
+}
+func (pkg *Pkg) License() string {
+	return pkg.license
+}
+func (pkg *Pkg) Vendor() string {
+	return pkg.vendor
+}
+func (pkg *Pkg) Group() string {
+	return pkg.group
+}
+func (pkg *Pkg) Packager() string {
+	return pkg.packager
+}
+func (pkg *Pkg) Buildhost() string {
+	return pkg.buildhost
+}
+func (pkg *Pkg) SourceRPM() string {
+	return pkg.sourcerpm
+}
+func (pkg *Pkg) BuildTime() time.Time {
+	return time.Unix(pkg.buildTime, 0)
+}
+
+// FileTime: The mtime of the rpm, when the repo was created
+func (pkg *Pkg) FileTime() time.Time {
+	return time.Unix(pkg.fileTime, 0)
+}
+
+// PackageSize: Size of the rpm
+func (pkg *Pkg) PackageSize() int64 {
+	return pkg.packageSize
+}
+
+// InstalledSize: Size of all the files, once installed
+func (pkg *Pkg) InstalledSize() int64 {
+	return pkg.installedSize
+}
+func (pkg *Pkg) ArchiveSize() int64 {
+	return pkg.archiveSize
+}
+
+// Location: Path of the rpm, relative to the repo baseurl
+func (pkg *Pkg) Location() string {
+	return pkg.location
+}
+
+// LocationBase: The baseurl to use for Location, if not the repo's
+func (pkg *Pkg) LocationBase() string {
+	return pkg.locationBase
+}
+
+// HeaderRange: Byte offsets of the rpm header, within the rpm
+func (pkg *Pkg) HeaderRange() (int64, int64) {
+	return pkg.hdrStart, pkg.hdrEnd
+}
+
 // Less: Comparison, for sorting
 func (pkg *Pkg) Cmp(o *Pkg) int {
 	if pkg == o {
@@ -97,12 +199,14 @@ func (pkg *Pkg) Cmp(o *Pkg) int {
 		return pkg.epoch - o.epoch
 	}
 
-	ret = rpmvercmp(pkg.version, o.version)
+	// Always compare the releases, unlike EVR.Compare, so the order of
+	// packages is total.
+	ret = Vercmp(pkg.version, o.version)
 	if ret != 0 {
 		return ret
 	}
 
-	ret = rpmvercmp(pkg.release, o.release)
+	ret = Vercmp(pkg.release, o.release)
 	if ret != 0 {
 		return ret
 	}
@@ -165,74 +269,86 @@ func (pkg *Pkg) Match(pattern string) bool {
 type Pkgs struct {
 	Repo *Repodata
 	Pkgs []*Pkg
+
+	idxLock sync.Mutex
+	idx     *pkgIndex
 }
 
 func (repo *Repodata) Load() (*Pkgs, error) {
-	var xmlData struct {
-		Packages []struct {
-			Name string `xml:"name"`
-			V    struct {
-				Epoch   int    `xml:"epoch,attr"`
-				Version string `xml:"ver,attr"`
-				Relase  string `xml:"rel,attr"`
-			} `xml:"version"`
-			Arch     string `xml:"arch"`
-			Checksum struct {
-				T string `xml:"type,attr"`
-				D string `xml:",chardata"`
-			} `xml:"checksum"`
-		} `xml:"package"`
-	}
-
-	primarygz, err := url2bytes(repo.Baseurl + repo.Primary.Path)
+	return repo.LoadContext(context.Background())
+}
+
+// LoadContext: Load, the download is cancelled with the ctx.
+func (repo *Repodata) LoadContext(ctx context.Context) (*Pkgs, error) {
+	ret := &Pkgs{Repo: repo}
+	err := repo.fetchStream(ctx, repo.Primary, func(r io.Reader) error {
+		ret.Pkgs = nil
+		return parsePrimary(r, func(p *Pkg) error {
+			ret.Pkgs = append(ret.Pkgs, p)
+			return nil
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if !hchks(primarygz, repo.Primary.Chks) {
-		err = fmt.Errorf("error: Checksum doesn't match for Primary")
-		return nil, err
-	}
+	sort.Sort(ByPkg(ret.Pkgs))
 
-	br := bytes.NewReader(primarygz)
-	zr, err := autounzip(br, repo.Primary.Path)
-	if err != nil {
-		panic(err)
-	}
+	return ret, nil
+}
 
-	var primarybuf bytes.Buffer
-	if _, err := io.Copy(&primarybuf, zr); err != nil {
-		panic(err)
+// loadByPkgID: Download the data, from the Repo, and give each of the
+// packages what parse found for its pkgid, with add. The packages all have
+// to be from the Repo, reset is called for each of them first. Nothing is
+// changed if the download fails.
+func (pkgs *Pkgs) loadByPkgID(ctx context.Context, d Data,
+	parse func(r io.Reader, add func(pkgid string, set func(*Pkg))) error,
+	reset func(*Pkg)) error {
+	ids := make(map[string]bool, len(pkgs.Pkgs))
+	for _, p := range pkgs.Pkgs {
+		ids[p.chk.Data] = true
+	}
+
+	var sets map[string]func(*Pkg)
+	err := pkgs.Repo.fetchStream(ctx, d, func(r io.Reader) error {
+		sets = make(map[string]func(*Pkg), len(ids))
+		return parse(r, func(pkgid string, set func(*Pkg)) {
+			if ids[pkgid] {
+				sets[pkgid] = set
+			}
+		})
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := zr.Close(); err != nil {
-		panic(err)
+	// Queries on pkgs itself can be running, other Pkgs holding the same
+	// packages can't, see LoadFilesContext. The index of pkgs is built
+	// again the next time it's needed, any others keep the old data.
+	pkgs.idxLock.Lock()
+	defer pkgs.idxLock.Unlock()
+	for _, p := range pkgs.Pkgs {
+		reset(p)
+		if set := sets[p.chk.Data]; set != nil {
+			set(p)
+		}
 	}
+	pkgs.idx = nil
 
-	primary := primarybuf.Bytes()
-	// fmt.Println(string(primary))
+	return nil
+}
 
-	err = xml.Unmarshal(primary, &xmlData)
-	if err != nil {
-		fmt.Printf("error: %v", err)
-		return nil, err
+// PkgURL: URL to download the package from
+func (repo *Repodata) PkgURL(pkg *Pkg) string {
+	base := pkg.locationBase
+	if base == "" {
+		base = repo.Baseurl
 	}
-
-	ret := &Pkgs{Repo: repo}
-	for _, xp := range xmlData.Packages {
-		p := &Pkg{}
-		p.name = xp.Name
-		p.arch = xp.Arch
-		p.version = xp.V.Version
-		p.release = xp.V.Relase
-		p.epoch = xp.V.Epoch
-		p.chk = Checksum{Kind: xp.Checksum.T, Data: xp.Checksum.D}
-		ret.Pkgs = append(ret.Pkgs, p)
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
 	}
 
-	sort.Sort(ByPkg(ret.Pkgs))
-
-	return ret, nil
+	return base + pkg.location
 }
 
 func (snap *Repodata) MustLoad() *Pkgs {
@@ -259,6 +375,58 @@ func (pkgs *Pkgs) Match(pattern string) *Pkgs {
 	return ret
 }
 
+// Latest: The n newest versions of each name.arch, like repoquery
+// --latest-limit. A negative n is all but the -n newest. The pkgs need to be
+// sorted, as from Load.
+func (pkgs *Pkgs) Latest(n int) *Pkgs {
+	return pkgs.latest(n, func(p *Pkg) string { return p.arch })
+}
+
+// LatestByName: Like Latest, but for each name, so the newest versions of any
+// arch.
+func (pkgs *Pkgs) LatestByName(n int) *Pkgs {
+	return pkgs.latest(n, func(p *Pkg) string { return "" })
+}
+
+// latest: Pkgs are sorted by name then EVR, so go backwards through each
+// name counting the EVRs of each key.
+func (pkgs *Pkgs) latest(n int, key func(*Pkg) string) *Pkgs {
+	keep := make([]bool, len(pkgs.Pkgs))
+	for i := 0; i < len(pkgs.Pkgs); {
+		j := i + 1
+		for j < len(pkgs.Pkgs) && pkgs.Pkgs[j].name == pkgs.Pkgs[i].name {
+			j++
+		}
+
+		counts := make(map[string]int)
+		last := make(map[string]*Pkg)
+		for k := j - 1; k >= i; k-- {
+			p := pkgs.Pkgs[k]
+			kp := key(p)
+			if last[kp] == nil || last[kp].EVR().Compare(p.EVR()) != 0 {
+				counts[kp]++
+			}
+			last[kp] = p
+
+			if n >= 0 {
+				keep[k] = counts[kp] <= n
+			} else {
+				keep[k] = counts[kp] > -n
+			}
+		}
+		i = j
+	}
+
+	ret := &Pkgs{Repo: pkgs.Repo}
+	for i, p := range pkgs.Pkgs {
+		if keep[i] {
+			ret.Pkgs = append(ret.Pkgs, p)
+		}
+	}
+
+	return ret
+}
+
 func (a *Pkgs) Merge(b *Pkgs) *Pkgs {
 	arepo := a.Repo
 	if a.Repo != b.Repo {
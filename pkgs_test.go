@@ -0,0 +1,60 @@
+package repos
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestLatest(t *testing.T) {
+	pkgs := &Pkgs{Pkgs: []*Pkg{
+		{name: "bar", version: "1", release: "1", arch: "noarch"},
+		{name: "foo", version: "1.1", release: "1", arch: "i686"},
+		{name: "foo", version: "1.2", release: "1", arch: "x86_64"},
+		{name: "foo", version: "1.2", release: "3", arch: "i686"},
+		{name: "foo", version: "1.2", release: "3", arch: "x86_64"},
+		{name: "foo", version: "1.3", release: "1", arch: "x86_64"},
+		{name: "foo", version: "1.3", release: "1", arch: "s390x"},
+	}}
+	sort.Sort(ByPkg(pkgs.Pkgs))
+
+	data := []struct {
+		pkgs *Pkgs
+		res  []string
+	}{
+		{pkgs.Latest(1), []string{"bar-1-1.noarch", "foo-1.2-3.i686",
+			"foo-1.3-1.s390x", "foo-1.3-1.x86_64"}},
+		{pkgs.Latest(-2), []string{"foo-1.2-1.x86_64"}},
+		{pkgs.LatestByName(1), []string{"bar-1-1.noarch", "foo-1.3-1.s390x",
+			"foo-1.3-1.x86_64"}},
+		{pkgs.CompatArch("x86_64").LatestByName(2), []string{"bar-1-1.noarch",
+			"foo-1.2-3.i686", "foo-1.2-3.x86_64", "foo-1.3-1.x86_64"}},
+		{pkgs.Arches("s390x", "noarch"), []string{"bar-1-1.noarch",
+			"foo-1.3-1.s390x"}},
+	}
+	for i := range data {
+		var res []string
+		for _, p := range data[i].pkgs.Pkgs {
+			res = append(res, p.Nvra())
+		}
+		if len(res) != len(data[i].res) {
+			t.Errorf("%d: got %v, want %v\n", i, res, data[i].res)
+			continue
+		}
+		for j := range res {
+			if res[j] != data[i].res[j] {
+				t.Errorf("%d: got %v, want %v\n", i, res, data[i].res)
+				break
+			}
+		}
+	}
+}
+
+func TestPkgCmpRelease(t *testing.T) {
+	a := &Pkg{name: "foo", version: "1.0"}
+	b := &Pkg{name: "foo", version: "1.0", release: "1"}
+	c := &Pkg{name: "foo", version: "1.0", release: "2"}
+	if a.Cmp(b) >= 0 || a.Cmp(c) >= 0 || b.Cmp(c) >= 0 {
+		t.Errorf("Cmp: got %d %d %d, want all < 0\n", a.Cmp(b), a.Cmp(c),
+			b.Cmp(c))
+	}
+}
@@ -0,0 +1,155 @@
+package repos
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+type xmlDeps []struct {
+	Name    string `xml:"name,attr"`
+	Flags   string `xml:"flags,attr"`
+	Epoch   int    `xml:"epoch,attr"`
+	Version string `xml:"ver,attr"`
+	Release string `xml:"rel,attr"`
+	Pre     string `xml:"pre,attr"`
+}
+
+func (xds xmlDeps) deps() []Dependency {
+	if len(xds) == 0 {
+		return nil
+	}
+
+	ret := make([]Dependency, 0, len(xds))
+	for _, xd := range xds {
+		dep := Dependency{Name: xd.Name, Flags: parseDepFlags(xd.Flags)}
+		dep.EVR = EVR{Epoch: xd.Epoch, Version: xd.Version, Release: xd.Release}
+		dep.Pre = xd.Pre != "" && xd.Pre != "0"
+		ret = append(ret, dep)
+	}
+	return ret
+}
+
+type xmlPkg struct {
+	Name string `xml:"name"`
+	V    struct {
+		Epoch   int    `xml:"epoch,attr"`
+		Version string `xml:"ver,attr"`
+		Relase  string `xml:"rel,attr"`
+	} `xml:"version"`
+	Arch     string `xml:"arch"`
+	Checksum struct {
+		T string `xml:"type,attr"`
+		D string `xml:",chardata"`
+	} `xml:"checksum"`
+	Summary     string `xml:"summary"`
+	Description string `xml:"description"`
+	Packager    string `xml:"packager"`
+	URL         string `xml:"url"`
+	Time        struct {
+		File  int64 `xml:"file,attr"`
+		Build int64 `xml:"build,attr"`
+	} `xml:"time"`
+	Size struct {
+		Package   int64 `xml:"package,attr"`
+		Installed int64 `xml:"installed,attr"`
+		Archive   int64 `xml:"archive,attr"`
+	} `xml:"size"`
+	Location struct {
+		Base string `xml:"base,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"location"`
+	License     string `xml:"format>license"`
+	Vendor      string `xml:"format>vendor"`
+	Group       string `xml:"format>group"`
+	Buildhost   string `xml:"format>buildhost"`
+	Sourcerpm   string `xml:"format>sourcerpm"`
+	HeaderRange struct {
+		Start int64 `xml:"start,attr"`
+		End   int64 `xml:"end,attr"`
+	} `xml:"format>header-range"`
+	Provides    xmlDeps  `xml:"format>provides>entry"`
+	Requires    xmlDeps  `xml:"format>requires>entry"`
+	Conflicts   xmlDeps  `xml:"format>conflicts>entry"`
+	Obsoletes   xmlDeps  `xml:"format>obsoletes>entry"`
+	Recommends  xmlDeps  `xml:"format>recommends>entry"`
+	Suggests    xmlDeps  `xml:"format>suggests>entry"`
+	Supplements xmlDeps  `xml:"format>supplements>entry"`
+	Enhances    xmlDeps  `xml:"format>enhances>entry"`
+	Files       []string `xml:"format>file"`
+}
+
+func (xp *xmlPkg) pkg() *Pkg {
+	p := &Pkg{}
+	p.name = xp.Name
+	p.arch = xp.Arch
+	p.version = xp.V.Version
+	p.release = xp.V.Relase
+	p.epoch = xp.V.Epoch
+	p.chk = Checksum{Kind: xp.Checksum.T, Data: xp.Checksum.D}
+	p.summary = xp.Summary
+	p.description = xp.Description
+	p.packager = xp.Packager
+	p.url = xp.URL
+	p.fileTime = xp.Time.File
+	p.buildTime = xp.Time.Build
+	p.packageSize = xp.Size.Package
+	p.installedSize = xp.Size.Installed
+	p.archiveSize = xp.Size.Archive
+	p.locationBase = xp.Location.Base
+	p.location = xp.Location.Href
+	p.license = xp.License
+	p.vendor = xp.Vendor
+	p.group = xp.Group
+	p.buildhost = xp.Buildhost
+	p.sourcerpm = xp.Sourcerpm
+	p.hdrStart = xp.HeaderRange.Start
+	p.hdrEnd = xp.HeaderRange.End
+	p.provides = xp.Provides.deps()
+	p.requires = xp.Requires.deps()
+	p.conflicts = xp.Conflicts.deps()
+	p.obsoletes = xp.Obsoletes.deps()
+	p.recommends = xp.Recommends.deps()
+	p.suggests = xp.Suggests.deps()
+	p.supplements = xp.Supplements.deps()
+	p.enhances = xp.Enhances.deps()
+	p.files = xp.Files
+
+	return p
+}
+
+// xmlEach: Decode each of the elements called name, one at a time, and pass
+// them to fn.
+func xmlEach(r io.Reader, name string, v interface{}, fn func() error) error {
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != name {
+			continue
+		}
+
+		if err := d.DecodeElement(v, &se); err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+}
+
+// parsePrimary: Parse primary.xml, passing each package to fn as it's read.
+func parsePrimary(r io.Reader, fn func(*Pkg) error) error {
+	var xp xmlPkg
+	return xmlEach(r, "package", &xp, func() error {
+		err := fn(xp.pkg())
+		xp = xmlPkg{}
+		return err
+	})
+}
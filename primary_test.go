@@ -0,0 +1,231 @@
+package repos
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// tPRIMARYRPM: A primary.xml as createrepo_c writes it, with all the rpm:
+// metadata, and a package from another baseurl.
+const tPRIMARYRPM = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="2">
+<package type="rpm">
+  <name>bash</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="4.4.19" rel="5.fc28"/>
+  <checksum type="sha256" pkgid="YES">2c1a4e0dd7f4d7b5f1b7b3ba9a6a0a8f1b8d6ee2f6de8c2fd0c7b0d6a1f2e3d4</checksum>
+  <summary>The GNU Bourne Again shell</summary>
+  <description>The GNU Bourne Again shell (Bash) is a shell and command language
+interpreter compatible with the Bourne shell (sh).</description>
+  <packager>Fedora Project</packager>
+  <url>https://www.gnu.org/software/bash</url>
+  <time file="1525000100" build="1524900000"/>
+  <size package="1563116" installed="6844744" archive="6858248"/>
+  <location href="Packages/b/bash-4.4.19-5.fc28.x86_64.rpm"/>
+  <format>
+    <rpm:license>GPLv3+</rpm:license>
+    <rpm:vendor>Fedora Project</rpm:vendor>
+    <rpm:group>System Environment/Shells</rpm:group>
+    <rpm:buildhost>buildvm-12.phx2.fedoraproject.org</rpm:buildhost>
+    <rpm:sourcerpm>bash-4.4.19-5.fc28.src.rpm</rpm:sourcerpm>
+    <rpm:header-range start="4504" end="34628"/>
+    <rpm:provides>
+      <rpm:entry name="/bin/sh"/>
+      <rpm:entry name="bash" flags="EQ" epoch="0" ver="4.4.19" rel="5.fc28"/>
+      <rpm:entry name="bash(x86-64)" flags="EQ" epoch="0" ver="4.4.19" rel="5.fc28"/>
+      <rpm:entry name="config(bash)" flags="EQ" epoch="0" ver="4.4.19" rel="5.fc28"/>
+    </rpm:provides>
+    <rpm:requires>
+      <rpm:entry name="/bin/sh" pre="1"/>
+      <rpm:entry name="filesystem" flags="GE" epoch="0" ver="3" pre="1"/>
+      <rpm:entry name="libc.so.6(GLIBC_2.15)(64bit)"/>
+      <rpm:entry name="libtinfo.so.6()(64bit)"/>
+      <rpm:entry name="rpmlib(BuiltinLuaScripts)" flags="LE" epoch="0" ver="4.2.2" rel="1" pre="1"/>
+    </rpm:requires>
+    <rpm:conflicts>
+      <rpm:entry name="filesystem" flags="LT" epoch="0" ver="3"/>
+    </rpm:conflicts>
+    <rpm:obsoletes>
+      <rpm:entry name="bash2" flags="LT" epoch="0" ver="3.0"/>
+    </rpm:obsoletes>
+    <rpm:recommends>
+      <rpm:entry name="bash-completion"/>
+    </rpm:recommends>
+    <rpm:suggests>
+      <rpm:entry name="bash-doc" flags="EQ" epoch="0" ver="4.4.19" rel="5.fc28"/>
+    </rpm:suggests>
+    <rpm:supplements>
+      <rpm:entry name="(bash-completion and readline)"/>
+    </rpm:supplements>
+    <rpm:enhances>
+      <rpm:entry name="readline"/>
+    </rpm:enhances>
+    <file>/etc/skel/.bashrc</file>
+    <file>/usr/bin/bash</file>
+    <file type="dir">/etc/skel</file>
+  </format>
+</package>
+<package type="rpm">
+  <name>zsh</name>
+  <arch>x86_64</arch>
+  <version epoch="1" ver="5.5.1" rel="1.fc28"/>
+  <checksum type="sha256" pkgid="YES">8f3c2b1a0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b</checksum>
+  <summary>Powerful interactive shell</summary>
+  <description>The zsh shell.</description>
+  <packager>Fedora Project</packager>
+  <url>http://zsh.sourceforge.net/</url>
+  <time file="1525000200" build="1524800000"/>
+  <size package="2953112" installed="7608592" archive="7624712"/>
+  <location xml:base="https://mirror.example/fedora/updates/28/x86_64/" href="z/zsh-5.5.1-1.fc28.x86_64.rpm"/>
+  <format>
+    <rpm:license>MIT</rpm:license>
+    <rpm:sourcerpm>zsh-5.5.1-1.fc28.src.rpm</rpm:sourcerpm>
+    <rpm:header-range start="4504" end="22208"/>
+  </format>
+</package>
+</metadata>
+`
+
+func tPARSE(t *testing.T, primary string) []*Pkg {
+	var pkgs []*Pkg
+	err := parsePrimary(strings.NewReader(primary), func(p *Pkg) error {
+		pkgs = append(pkgs, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkgs
+}
+
+func TestParsePrimary(t *testing.T) {
+	pkgs := tPARSE(t, tPRIMARYRPM)
+	if len(pkgs) != 2 {
+		t.Fatalf("got %d pkgs\n", len(pkgs))
+	}
+
+	bash := pkgs[0]
+	str := []struct {
+		name string
+		ret  string
+		res  string
+	}{
+		{"Nevra", bash.Nevra(), "bash-0:4.4.19-5.fc28.x86_64"},
+		{"Summary", bash.Summary(), "The GNU Bourne Again shell"},
+		{"URL", bash.URL(), "https://www.gnu.org/software/bash"},
+		{"Packager", bash.Packager(), "Fedora Project"},
+		{"License", bash.License(), "GPLv3+"},
+		{"Vendor", bash.Vendor(), "Fedora Project"},
+		{"Group", bash.Group(), "System Environment/Shells"},
+		{"Buildhost", bash.Buildhost(), "buildvm-12.phx2.fedoraproject.org"},
+		{"SourceRPM", bash.SourceRPM(), "bash-4.4.19-5.fc28.src.rpm"},
+		{"Location", bash.Location(), "Packages/b/bash-4.4.19-5.fc28.x86_64.rpm"},
+		{"LocationBase", bash.LocationBase(), ""},
+		{"Checksum", bash.Checksum().Kind, "sha256"},
+	}
+	for _, s := range str {
+		if s.ret != s.res {
+			t.Errorf("%s\n  res = %q\n  ret = %q\n", s.name, s.res, s.ret)
+		}
+	}
+	if !strings.HasPrefix(bash.Description(), "The GNU Bourne Again shell (Bash)") {
+		t.Errorf("Description: got %q\n", bash.Description())
+	}
+
+	if bash.PackageSize() != 1563116 || bash.InstalledSize() != 6844744 ||
+		bash.ArchiveSize() != 6858248 {
+		t.Errorf("sizes: got %d %d %d\n", bash.PackageSize(),
+			bash.InstalledSize(), bash.ArchiveSize())
+	}
+	if !bash.FileTime().Equal(time.Unix(1525000100, 0)) ||
+		!bash.BuildTime().Equal(time.Unix(1524900000, 0)) {
+		t.Errorf("times: got %v %v\n", bash.FileTime(), bash.BuildTime())
+	}
+	if start, end := bash.HeaderRange(); start != 4504 || end != 34628 {
+		t.Errorf("HeaderRange: got %d-%d\n", start, end)
+	}
+
+	zsh := pkgs[1]
+	if zsh.EVR().Epoch != 1 ||
+		zsh.LocationBase() != "https://mirror.example/fedora/updates/28/x86_64/" {
+		t.Errorf("zsh: got %s from %q\n", zsh.Nevra(), zsh.LocationBase())
+	}
+}
+
+func TestPkgURL(t *testing.T) {
+	pkgs := tPARSE(t, tPRIMARYRPM)
+
+	data := []struct {
+		baseurl string
+		pkg     *Pkg
+		res     string
+	}{
+		{"https://dl.example/fedora/28/x86_64/os/", pkgs[0],
+			"https://dl.example/fedora/28/x86_64/os/Packages/b/bash-4.4.19-5.fc28.x86_64.rpm"},
+		{"https://dl.example/fedora/28/x86_64/os", pkgs[0],
+			"https://dl.example/fedora/28/x86_64/os/Packages/b/bash-4.4.19-5.fc28.x86_64.rpm"},
+		{"https://dl.example/fedora/28/x86_64/os/", pkgs[1],
+			"https://mirror.example/fedora/updates/28/x86_64/z/zsh-5.5.1-1.fc28.x86_64.rpm"},
+	}
+	for i := range data {
+		repo := &Repodata{Baseurl: data[i].baseurl}
+		if ret := repo.PkgURL(data[i].pkg); ret != data[i].res {
+			t.Errorf("PkgURL(%s)\n  res = %s\n  ret = %s\n", data[i].pkg,
+				data[i].res, ret)
+		}
+	}
+}
+
+func TestParsePrimaryDeps(t *testing.T) {
+	bash := tPARSE(t, tPRIMARYRPM)[0]
+
+	data := []struct {
+		name string
+		deps []Dependency
+		res  []string
+	}{
+		{"Provides", bash.Provides(), []string{"/bin/sh",
+			"bash = 4.4.19-5.fc28", "bash(x86-64) = 4.4.19-5.fc28",
+			"config(bash) = 4.4.19-5.fc28"}},
+		{"Requires", bash.Requires(), []string{"/bin/sh", "filesystem >= 3",
+			"libc.so.6(GLIBC_2.15)(64bit)", "libtinfo.so.6()(64bit)",
+			"rpmlib(BuiltinLuaScripts) <= 4.2.2-1"}},
+		{"Conflicts", bash.Conflicts(), []string{"filesystem < 3"}},
+		{"Obsoletes", bash.Obsoletes(), []string{"bash2 < 3.0"}},
+		{"Recommends", bash.Recommends(), []string{"bash-completion"}},
+		{"Suggests", bash.Suggests(), []string{"bash-doc = 4.4.19-5.fc28"}},
+		{"Supplements", bash.Supplements(),
+			[]string{"(bash-completion and readline)"}},
+		{"Enhances", bash.Enhances(), []string{"readline"}},
+	}
+	for _, d := range data {
+		if len(d.deps) != len(d.res) {
+			t.Errorf("%s: got %v\n", d.name, d.deps)
+			continue
+		}
+		for i := range d.res {
+			if ret := d.deps[i].String(); ret != d.res[i] {
+				t.Errorf("%s %d\n  res = %s\n  ret = %s\n", d.name, i,
+					d.res[i], ret)
+			}
+		}
+	}
+
+	for i, pre := range []bool{true, true, false, false, true} {
+		if bash.Requires()[i].Pre != pre {
+			t.Errorf("Requires %d: Pre is %v\n", i, !pre)
+		}
+	}
+	if bash.Provides()[1].Pre {
+		t.Errorf("Provides: Pre is set\n")
+	}
+	if !bash.Supplements()[0].IsRich() {
+		t.Errorf("Supplements: not rich\n")
+	}
+
+	zsh := tPARSE(t, tPRIMARYRPM)[1]
+	if zsh.Provides() != nil || zsh.Requires() != nil {
+		t.Errorf("zsh: got %v %v\n", zsh.Provides(), zsh.Requires())
+	}
+}
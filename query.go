@@ -0,0 +1,108 @@
+package repos
+
+type depEntry struct {
+	pkg *Pkg
+	dep Dependency
+}
+
+// pkgIndex: The indexes used by the queries, never changed once built so
+// they can be used without holding Pkgs.idxLock.
+type pkgIndex struct {
+	prov  map[string][]depEntry
+	req   map[string][]depEntry
+	files map[string][]*Pkg
+}
+
+// index: The provides (including files) and requires (including each part of
+// rich ones) indexes, built the first time they are needed. Changing Pkgs
+// after that isn't seen by the queries, apart from LoadFiles.
+func (pkgs *Pkgs) index() *pkgIndex {
+	pkgs.idxLock.Lock()
+	defer pkgs.idxLock.Unlock()
+
+	if pkgs.idx != nil {
+		return pkgs.idx
+	}
+
+	idx := &pkgIndex{prov: make(map[string][]depEntry),
+		req: make(map[string][]depEntry), files: make(map[string][]*Pkg)}
+	for _, p := range pkgs.Pkgs {
+		for _, dep := range p.provides {
+			idx.prov[dep.Name] = append(idx.prov[dep.Name],
+				depEntry{pkg: p, dep: dep})
+		}
+		files := p.files
+		if p.filelist != nil {
+			files = make([]string, 0, len(p.filelist))
+			for _, f := range p.filelist {
+				files = append(files, f.Path)
+			}
+		}
+		for _, name := range files {
+			idx.prov[name] = append(idx.prov[name],
+				depEntry{pkg: p, dep: Dependency{Name: name}})
+			idx.files[name] = append(idx.files[name], p)
+		}
+		for _, dep := range p.requires {
+			deps := []Dependency{dep}
+			if dep.IsRich() {
+				if rd, err := ParseRichDep(dep.Name); err == nil {
+					deps = rd.leaves()
+				}
+			}
+			for _, dep := range deps {
+				idx.req[dep.Name] = append(idx.req[dep.Name],
+					depEntry{pkg: p, dep: dep})
+			}
+		}
+	}
+	pkgs.idx = idx
+	return idx
+}
+
+// whatMatches: The packages, in order, with an entry overlapping the dep.
+func (pkgs *Pkgs) whatMatches(idx []depEntry, dep Dependency) *Pkgs {
+	ret := &Pkgs{Repo: pkgs.Repo}
+	var last *Pkg
+	for _, de := range idx {
+		if de.pkg == last || !de.dep.overlaps(dep) {
+			continue
+		}
+		ret.Pkgs = append(ret.Pkgs, de.pkg)
+		last = de.pkg
+	}
+
+	return ret
+}
+
+// WhatProvidesDep: The packages with a provide matching the dependency.
+func (pkgs *Pkgs) WhatProvidesDep(dep Dependency) *Pkgs {
+	return pkgs.whatMatches(pkgs.index().prov[dep.Name], dep)
+}
+
+// WhatRequiresDep: The packages with a require matching the dependency.
+func (pkgs *Pkgs) WhatRequiresDep(dep Dependency) *Pkgs {
+	return pkgs.whatMatches(pkgs.index().req[dep.Name], dep)
+}
+
+// WhatProvides: Like repoquery --whatprovides, the capability is parsed
+// with ParseDependency. Eg. "foo", "foo >= 1.2" or "libc.so.6()(64bit)"
+func (pkgs *Pkgs) WhatProvides(capability string) (*Pkgs, error) {
+	dep, err := ParseDependency(capability)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkgs.WhatProvidesDep(dep), nil
+}
+
+// WhatRequires: Like repoquery --whatrequires, the capability is parsed
+// with ParseDependency.
+func (pkgs *Pkgs) WhatRequires(capability string) (*Pkgs, error) {
+	dep, err := ParseDependency(capability)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkgs.WhatRequiresDep(dep), nil
+}
@@ -0,0 +1,64 @@
+package repos
+
+import "testing"
+
+func TestWhatProvides(t *testing.T) {
+	pkgs := &Pkgs{Pkgs: []*Pkg{
+		tPKG(t, "foo-1.0-1.x86_64", []string{"foo", "libfoo.so.1()(64bit)"}),
+		tPKG(t, "foo-1.3-1.x86_64"),
+		tPKG(t, "bar-1-1.x86_64", []string{"foo = 0.9"},
+			[]string{"foo >= 1.2", "/usr/bin/foo"}),
+		tPKG(t, "baz-1-1.x86_64", nil, []string{"foo", "(foo if bar)"}),
+	}}
+	pkgs.Pkgs[1].files = []string{"/usr/bin/foo"}
+
+	data := []struct {
+		what string
+		c    string
+		res  []string
+	}{
+		// foo-1.0 provides "foo" and "foo = 1.0-1", but is only listed once.
+		{"provides", "foo", []string{"foo-1.0-1.x86_64", "foo-1.3-1.x86_64",
+			"bar-1-1.x86_64"}},
+		{"provides", "foo >= 1.2", []string{"foo-1.0-1.x86_64",
+			"foo-1.3-1.x86_64"}},
+		{"provides", "foo>1.0-1", []string{"foo-1.0-1.x86_64",
+			"foo-1.3-1.x86_64"}},
+		{"provides", "foo < 1", []string{"foo-1.0-1.x86_64", "bar-1-1.x86_64"}},
+		{"provides", "/usr/bin/foo", []string{"foo-1.3-1.x86_64"}},
+		{"provides", "libfoo.so.1()(64bit)", []string{"foo-1.0-1.x86_64"}},
+		{"requires", "foo", []string{"bar-1-1.x86_64", "baz-1-1.x86_64"}},
+		{"requires", "foo = 1.0-1", []string{"baz-1-1.x86_64"}},
+		{"requires", "bar", []string{"baz-1-1.x86_64"}},
+		{"requires", "/usr/bin/foo", []string{"bar-1-1.x86_64"}},
+	}
+	for i := range data {
+		var ret *Pkgs
+		var err error
+		if data[i].what == "provides" {
+			ret, err = pkgs.WhatProvides(data[i].c)
+		} else {
+			ret, err = pkgs.WhatRequires(data[i].c)
+		}
+		if err != nil {
+			t.Errorf("%s %s: %v\n", data[i].what, data[i].c, err)
+			continue
+		}
+		var names []string
+		for _, p := range ret.Pkgs {
+			names = append(names, p.Nvra())
+		}
+		if len(names) != len(data[i].res) {
+			t.Errorf("%s %s\n  res = %v\n  ret = %v\n", data[i].what,
+				data[i].c, data[i].res, names)
+			continue
+		}
+		for j := range names {
+			if names[j] != data[i].res[j] {
+				t.Errorf("%s %s\n  res = %v\n  ret = %v\n", data[i].what,
+					data[i].c, data[i].res, names)
+				break
+			}
+		}
+	}
+}
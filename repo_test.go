@@ -0,0 +1,108 @@
+package repos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+const tPRIMARY = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="2">
+<package type="rpm">
+  <name>foo</name>
+  <arch>noarch</arch>
+  <version epoch="0" ver="1.0" rel="1"/>
+  <checksum type="sha256" pkgid="YES">abcd</checksum>
+  <location href="foo-1.0-1.noarch.rpm"/>
+  <format>
+    <file>/usr/bin/foo</file>
+  </format>
+</package>
+<package type="rpm">
+  <name>bar</name>
+  <arch>x86_64</arch>
+  <version epoch="1" ver="2.0" rel="1"/>
+  <checksum type="sha256" pkgid="YES">ef01</checksum>
+  <location href="bar-2.0-1.x86_64.rpm"/>
+</package>
+</metadata>
+`
+
+// tREPODATA: A repo, in a new directory, with the (uncompressed) data for
+// each type in repomd.xml. primary is tPRIMARY if it isn't given.
+func tREPODATA(t *testing.T, data map[string]string) string {
+	dir, err := ioutil.TempDir("", "repos-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "repodata"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := data["primary"]; !ok {
+		ndata := map[string]string{"primary": tPRIMARY}
+		for k, v := range data {
+			ndata[k] = v
+		}
+		data = ndata
+	}
+	var types []string
+	for k := range data {
+		types = append(types, k)
+	}
+	sort.Strings(types)
+
+	repomd := `<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo">
+  <revision>1</revision>
+`
+	for _, k := range types {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		fmt.Fprint(zw, data[k])
+		zw.Close()
+
+		path := "repodata/" + k + ".xml.gz"
+		if err := ioutil.WriteFile(filepath.Join(dir, path), buf.Bytes(),
+			0644); err != nil {
+			t.Fatal(err)
+		}
+		repomd += fmt.Sprintf(`  <data type="%s">
+    <checksum type="sha256">%x</checksum>
+    <location href="%s"/>
+    <size>%d</size>
+  </data>
+`, k, sha256.Sum256(buf.Bytes()), path, buf.Len())
+	}
+	repomd += "</repomd>\n"
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "repodata", "repomd.xml"),
+		[]byte(repomd), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+// tLOAD: Load the packages from the repo in dir.
+func tLOAD(t *testing.T, dir string) *Pkgs {
+	snap, err := Baseurl(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repomd, err := snap.RepoMD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := repomd.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkgs
+}
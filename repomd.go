@@ -1,24 +1,35 @@
 package repos
 
 import (
+	"context"
 	"encoding/xml"
-	"fmt"
+	"errors"
+	"io"
+	"io/ioutil"
 	"strings"
 	"time"
 )
 
 type Repodata struct {
-	Baseurl  string
-	Revision int
-	Primary  Data
-	Files    Data
-	GrpRAW   Data
-	GrpGZ    Data
-	Other    Data
-	ModMD    Data
+	Baseurl    string
+	Mirrors    []string // Baseurls to try, in order, Baseurl is first
+	Revision   int
+	Primary    Data
+	Files      Data
+	GrpRAW     Data
+	GrpGZ      Data
+	Other      Data
+	ModMD      Data
+	Updateinfo Data
+	Fetcher    Fetcher
 }
 
 func (snap *Snapshot) RepoMD() (*Repodata, error) {
+	return snap.RepoMDContext(context.Background())
+}
+
+// RepoMDContext: RepoMD, the downloads are cancelled with the ctx.
+func (snap *Snapshot) RepoMDContext(ctx context.Context) (*Repodata, error) {
 	var xmlData struct {
 		Revision int `xml:"revision"`
 		Data     []struct {
@@ -37,10 +48,14 @@ func (snap *Snapshot) RepoMD() (*Repodata, error) {
 
 	var err error
 	var repomd []byte
-	var baseurl string
+	var mirrors []string
 
 	for i := range snap.URLs {
-		repomd, err = url2bytes(snap.URLs[i].URL)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		repomd, err = url2bytes(ctx, snap.Fetcher, snap.URLs[i].URL)
 		if err != nil {
 			//			fmt.Printf("error: %v", err)
 			//			return nil, err
@@ -48,21 +63,33 @@ func (snap *Snapshot) RepoMD() (*Repodata, error) {
 			continue
 		}
 
-		if !hchks(repomd, snap.Repomd.Chks) {
-			err = fmt.Errorf("error: Checksum doesn't match for repomd")
+		if len(repomd) == 0 {
+			err = errors.New("empty repomd.xml: " + snap.URLs[i].URL)
+			continue
+		}
+
+		if err = snap.repomdOK(repomd); err != nil {
+			// A cached copy from a stale mirror would be used again.
+			if cc, ok := snap.Fetcher.(cacheCleaner); ok {
+				cc.dropURL(snap.URLs[i].URL)
+			}
 			repomd = nil
 			//			return nil, err
 			continue
 		}
 
-		// FIXME: Pass all urls down
-		baseurl = strings.TrimSuffix(snap.URLs[i].URL, "repodata/repomd.xml")
+		// Mirrors that failed for repomd.xml are skipped, as they are
+		// unlikely to have the rest of the data.
+		for _, u := range snap.URLs[i:] {
+			mirrors = append(mirrors,
+				strings.TrimSuffix(u.URL, "repodata/repomd.xml"))
+		}
 
 		// fmt.Println(string(repomd))
 		break
 	}
 	if len(repomd) == 0 {
-		return nil, err
+		return nil, &RepoMDError{URLs: len(snap.URLs), Err: err}
 	}
 
 	err = xml.Unmarshal(repomd, &xmlData)
@@ -71,7 +98,8 @@ func (snap *Snapshot) RepoMD() (*Repodata, error) {
 		return nil, err
 	}
 
-	ret := &Repodata{Baseurl: baseurl}
+	ret := &Repodata{Baseurl: mirrors[0], Mirrors: mirrors,
+		Fetcher: snap.Fetcher}
 	ret.Revision = xmlData.Revision
 
 	for i := range xmlData.Data {
@@ -80,7 +108,7 @@ func (snap *Snapshot) RepoMD() (*Repodata, error) {
 		switch v.T {
 		case "primary":
 			d = &ret.Primary
-		case "files":
+		case "filelists", "files":
 			d = &ret.Files
 		case "other":
 			d = &ret.Other
@@ -90,9 +118,9 @@ func (snap *Snapshot) RepoMD() (*Repodata, error) {
 			d = &ret.GrpGZ
 		case "modules":
 			d = &ret.ModMD
-		case "prestodelta":
-			fallthrough
 		case "updateinfo":
+			d = &ret.Updateinfo
+		case "prestodelta":
 			fallthrough
 		default:
 			continue
@@ -102,5 +130,124 @@ func (snap *Snapshot) RepoMD() (*Repodata, error) {
 		d.TM = time.Unix(int64(v.Timestamp), 0)
 		d.Chks = []Checksum{{Kind: v.Checksum.T, Data: v.Checksum.D}}
 	}
+
+	if cc, ok := snap.Fetcher.(cacheCleaner); ok {
+		cc.keepData([]Data{ret.Primary, ret.Files, ret.GrpRAW, ret.GrpGZ,
+			ret.Other, ret.ModMD, ret.Updateinfo})
+	}
 	return ret, err
 }
+
+// repomdOK: Does the repomd.xml data match the snapshot, or any of the
+// alternates.
+func (snap *Snapshot) repomdOK(repomd []byte) error {
+	err := hchks(snap.Repomd.Path, repomd, snap.Repomd.Chks)
+	if err == nil {
+		return nil
+	}
+	for _, alt := range snap.Alternates {
+		if len(alt.Chks) > 0 && hchks(alt.Path, repomd, alt.Chks) == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// errReader: Remembers the first error from the io.Reader, apart from EOF.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (er *errReader) Read(p []byte) (int, error) {
+	n, err := er.r.Read(p)
+	if err != nil && err != io.EOF && er.err == nil {
+		er.err = err
+	}
+	return n, err
+}
+
+// fetchStream: Download the data, trying each mirror in turn, and pass it to
+// fn as it is decompressed. The checksums are only known to match once fn
+// returns, so fn is called again with the next mirror's data when they
+// don't.
+func (repo *Repodata) fetchStream(ctx context.Context, d Data,
+	fn func(io.Reader) error) error {
+	mirrors := repo.Mirrors
+	if len(mirrors) == 0 {
+		mirrors = []string{repo.Baseurl}
+	}
+
+	var err error
+	for _, baseurl := range mirrors {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var retry bool
+		retry, err = repo.fetchStreamURL(ctx, baseurl+d.Path, d, fn)
+		if err == nil || !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+// fetchStreamURL: fetchStream for a single mirror, returns true if the
+// error means the next mirror should be tried.
+func (repo *Repodata) fetchStreamURL(ctx context.Context, url string, d Data,
+	fn func(io.Reader) error) (bool, error) {
+	cw, err := newChksWriter(d.Chks)
+	if err != nil {
+		return false, err
+	}
+
+	var resp *Response
+	if df, ok := fetcher(repo.Fetcher).(DataFetcher); ok {
+		resp, err = df.FetchData(ctx, url, d)
+	} else {
+		resp, err = fetcher(repo.Fetcher).Fetch(ctx, url)
+	}
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	body := &errReader{r: resp.Body}
+	tr := io.TeeReader(body, cw)
+	// Read anything that wasn't, so the checksums cover all of it.
+	verify := func() (bool, error) {
+		if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+			return true, err
+		}
+		if body.err != nil {
+			return true, body.err
+		}
+		if err := cw.verify(d.Path); err != nil {
+			return true, err
+		}
+		return false, nil
+	}
+
+	zr, err := autounzip(tr, d.Path)
+	if err != nil {
+		if retry, verr := verify(); verr != nil {
+			return retry, verr
+		}
+		return false, &DecompressError{Path: d.Path, Err: err}
+	}
+	defer zr.Close()
+
+	zer := &errReader{r: zr}
+	ferr := fn(zer)
+
+	if retry, verr := verify(); verr != nil {
+		return retry, verr
+	}
+	if zer.err != nil {
+		return false, &DecompressError{Path: d.Path, Err: zer.err}
+	}
+	return false, ferr
+}
@@ -0,0 +1,216 @@
+package repos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tGZIP(s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	fmt.Fprint(zw, s)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// tMIRRORS: A server with a mirror for each of the primaries, at /0/, /1/,
+// etc. A nil primary is a 404. The counts are the requests to each mirror.
+func tMIRRORS(t *testing.T, good []byte,
+	primaries ...[]byte) (*Repodata, []int, func()) {
+	counts := make([]int, len(primaries))
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var i int
+			var name string
+			fmt.Sscanf(r.URL.Path, "/%d/%s", &i, &name)
+			if i < 0 || i >= len(primaries) || name != "primary.xml.gz" {
+				http.NotFound(w, r)
+				return
+			}
+			counts[i]++
+			if primaries[i] == nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(primaries[i])
+		}))
+
+	repo := &Repodata{}
+	for i := range primaries {
+		repo.Mirrors = append(repo.Mirrors, fmt.Sprintf("%s/%d/", srv.URL, i))
+	}
+	repo.Baseurl = repo.Mirrors[0]
+	repo.Primary = Data{Path: "primary.xml.gz", Size: len(good),
+		Chks: []Checksum{{Kind: "sha256",
+			Data: fmt.Sprintf("%x", sha256.Sum256(good))}}}
+
+	return repo, counts, srv.Close
+}
+
+func tNAMES(pkgs *Pkgs) string {
+	var names []string
+	for _, p := range pkgs.Pkgs {
+		names = append(names, p.Nvra())
+	}
+	return strings.Join(names, " ")
+}
+
+func TestMirrorFailover(t *testing.T) {
+	good := tGZIP(tPRIMARY)
+	other := tGZIP(strings.Replace(tPRIMARY, "<name>bar</name>",
+		"<name>baz</name>", 1))
+
+	repo, counts, done := tMIRRORS(t, good, nil, other, good)
+	defer done()
+
+	pkgs, err := repo.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := tNAMES(pkgs); names != "bar-2.0-1.x86_64 foo-1.0-1.noarch" {
+		t.Errorf("Load: got %s\n", names)
+	}
+	if counts[0] != 1 || counts[1] != 1 || counts[2] != 1 {
+		t.Errorf("requests: got %v\n", counts)
+	}
+}
+
+func TestFetchStreamTruncated(t *testing.T) {
+	// The checksum matches, so the data is as it should be and another
+	// mirror won't help.
+	good := tGZIP(tPRIMARY)
+	trunc := good[:len(good)/2]
+
+	repo, counts, done := tMIRRORS(t, trunc, trunc, good)
+	defer done()
+
+	_, err := repo.Load()
+	var derr *DecompressError
+	if !errors.As(err, &derr) {
+		t.Errorf("Load: got %v, want a DecompressError\n", err)
+	}
+	if counts[0] != 1 || counts[1] != 0 {
+		t.Errorf("requests: got %v, want no retry\n", counts)
+	}
+}
+
+func TestFetchStreamChecksumReset(t *testing.T) {
+	// Parses fine, but the checksum only fails after fn has seen it all.
+	good := tGZIP(tPRIMARY)
+	more := tGZIP(strings.Replace(tPRIMARY, "</metadata>", `<package type="rpm">
+  <name>extra</name>
+  <arch>noarch</arch>
+  <version epoch="0" ver="1" rel="1"/>
+</package>
+</metadata>`, 1))
+
+	repo, counts, done := tMIRRORS(t, good, more, good)
+	defer done()
+
+	pkgs, err := repo.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := tNAMES(pkgs); names != "bar-2.0-1.x86_64 foo-1.0-1.noarch" {
+		t.Errorf("Load: got %s, want the results reset\n", names)
+	}
+	if counts[0] != 1 || counts[1] != 1 {
+		t.Errorf("requests: got %v\n", counts)
+	}
+}
+
+func TestFetchStreamCorrupt(t *testing.T) {
+	// A bad download, that fn fails to parse, so the next mirror is tried.
+	good := tGZIP(tPRIMARY)
+	corrupt := tGZIP(strings.Replace(tPRIMARY, "</name>", "</nme>", 1))
+
+	repo, counts, done := tMIRRORS(t, good, corrupt, good)
+	defer done()
+
+	pkgs, err := repo.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := tNAMES(pkgs); names != "bar-2.0-1.x86_64 foo-1.0-1.noarch" {
+		t.Errorf("Load: got %s\n", names)
+	}
+	if counts[0] != 1 || counts[1] != 1 {
+		t.Errorf("requests: got %v\n", counts)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			counts[strings.Split(r.URL.Path, "/")[1]]++
+			mu.Unlock()
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+		}))
+	defer srv.Close()
+	defer close(release)
+
+	good := tGZIP(tPRIMARY)
+	repo := &Repodata{Baseurl: srv.URL + "/0/",
+		Mirrors: []string{srv.URL + "/0/", srv.URL + "/1/"},
+		Primary: Data{Path: "primary.xml.gz", Size: len(good),
+			Chks: []Checksum{{Kind: "sha256",
+				Data: fmt.Sprintf("%x", sha256.Sum256(good))}}}}
+	snap := &Snapshot{Repomd: Data{Path: "repodata/repomd.xml"}}
+	for _, m := range repo.Mirrors {
+		snap.URLs = append(snap.URLs, URL{URL: m + "repodata/repomd.xml",
+			Protocol: "http"})
+	}
+
+	data := []struct {
+		name string
+		fn   func(context.Context) error
+	}{
+		{"LoadContext", func(ctx context.Context) error {
+			_, err := repo.LoadContext(ctx)
+			return err
+		}},
+		{"RepoMDContext", func(ctx context.Context) error {
+			_, err := snap.RepoMDContext(ctx)
+			return err
+		}},
+	}
+	for _, d := range data {
+		mu.Lock()
+		counts = make(map[string]int)
+		mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(),
+			50*time.Millisecond)
+		start := time.Now()
+		err := d.fn(ctx)
+		cancel()
+		if err != ctx.Err() {
+			t.Errorf("%s: got %v, want %v\n", d.name, err, ctx.Err())
+		}
+		if took := time.Since(start); took > 5*time.Second {
+			t.Errorf("%s: took %v\n", d.name, took)
+		}
+		mu.Lock()
+		if counts["0"] != 1 || counts["1"] != 0 {
+			t.Errorf("%s: requests %v\n", d.name, counts)
+		}
+		mu.Unlock()
+	}
+}
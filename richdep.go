@@ -0,0 +1,295 @@
+package repos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RichOp: The operator of a rich (boolean) dependency.
+type RichOp int
+
+const (
+	RichDepend RichOp = iota // Not an operator, a single Dependency
+	RichAnd
+	RichOr
+	RichIf
+	RichUnless
+	RichWith
+	RichWithout
+)
+
+var richOps = map[string]RichOp{
+	"and": RichAnd, "or": RichOr, "if": RichIf, "unless": RichUnless,
+	"with": RichWith, "without": RichWithout,
+}
+
+func (op RichOp) String() string {
+	for k, v := range richOps {
+		if v == op {
+			return k
+		}
+	}
+	return ""
+}
+
+// RichDep: A node of a parsed rich dependency, Eg. "(a or (b and c))".
+// For RichDepend the Dep is used, else Args are the operands. RichIf and
+// RichUnless have a third argument when there's an else.
+type RichDep struct {
+	Op   RichOp
+	Dep  Dependency
+	Args []*RichDep
+}
+
+func (rd *RichDep) String() string {
+	if rd.Op == RichDepend {
+		return rd.Dep.String()
+	}
+
+	args := make([]string, 0, len(rd.Args))
+	for _, arg := range rd.Args {
+		args = append(args, arg.String())
+	}
+	if len(args) == 3 && (rd.Op == RichIf || rd.Op == RichUnless) {
+		return fmt.Sprintf("(%s %s %s else %s)", args[0], rd.Op, args[1],
+			args[2])
+	}
+	return "(" + strings.Join(args, " "+rd.Op.String()+" ") + ")"
+}
+
+// IsRich: Is the dependency a rich one, that needs ParseRichDep.
+func (dep Dependency) IsRich() bool {
+	return strings.HasPrefix(dep.Name, "(")
+}
+
+type richParser struct {
+	s   string
+	pos int
+}
+
+func (p *richParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("bad rich dependency %q at %d: %s", p.s, p.pos,
+		fmt.Sprintf(format, args...))
+}
+
+func (p *richParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// word: Like rpm, a word ends at a space or at a ) that isn't part of it.
+// Eg. "libfoo.so.1()(64bit))" is "libfoo.so.1()(64bit)".
+func (p *richParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	depth := 0
+	for ; p.pos < len(p.s); p.pos++ {
+		c := p.s[p.pos]
+		if c == ' ' || c == '\t' {
+			break
+		}
+		if c == '(' {
+			depth++
+		}
+		if c == ')' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *richParser) peekWord() string {
+	pos := p.pos
+	w := p.word()
+	p.pos = pos
+	return w
+}
+
+func (p *richParser) term() (*RichDep, error) {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == '(' {
+		return p.rich()
+	}
+
+	name := p.word()
+	if name == "" {
+		return nil, p.errorf("no dependency name")
+	}
+	if _, ok := richOps[name]; ok || name == "else" {
+		return nil, p.errorf("unexpected %q", name)
+	}
+	dep := Dependency{Name: name}
+
+	if flags, ok := depOps[p.peekWord()]; ok {
+		p.word()
+		evr, err := ParseEVR(p.word())
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		dep.Flags = flags
+		dep.EVR = evr
+	}
+
+	return &RichDep{Op: RichDepend, Dep: dep}, nil
+}
+
+func (p *richParser) rich() (*RichDep, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) || p.s[p.pos] != '(' {
+		return nil, p.errorf("expected (")
+	}
+	p.pos++
+
+	first, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	ret := first
+
+	if w := p.peekWord(); w != "" {
+		op, ok := richOps[w]
+		if !ok {
+			return nil, p.errorf("unknown operator %q", w)
+		}
+		p.word()
+
+		ret = &RichDep{Op: op, Args: []*RichDep{first}}
+		for {
+			arg, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			ret.Args = append(ret.Args, arg)
+
+			w := p.peekWord()
+			if w == op.String() && (op == RichAnd || op == RichOr ||
+				op == RichWith) {
+				p.word()
+				continue
+			}
+			if w == "else" && len(ret.Args) == 2 &&
+				(op == RichIf || op == RichUnless) {
+				p.word()
+				continue
+			}
+			break
+		}
+	}
+
+	p.skipSpace()
+	if p.pos >= len(p.s) || p.s[p.pos] != ')' {
+		return nil, p.errorf("expected )")
+	}
+	p.pos++
+
+	return ret, nil
+}
+
+// ParseRichDep: Parse an rpm boolean dependency, Eg. "(foo if bar)" or
+// "(a or (b and c >= 1.2))". Mixing operators needs more (), like rpm.
+func ParseRichDep(s string) (*RichDep, error) {
+	p := &richParser{s: strings.TrimSpace(s)}
+	ret, err := p.rich()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, p.errorf("trailing data")
+	}
+
+	return ret, nil
+}
+
+// leaves: All the single dependencies in the rich dependency.
+func (rd *RichDep) leaves() []Dependency {
+	if rd.Op == RichDepend {
+		return []Dependency{rd.Dep}
+	}
+
+	var ret []Dependency
+	for _, arg := range rd.Args {
+		ret = append(ret, arg.leaves()...)
+	}
+	return ret
+}
+
+// eval: Is the rich dependency true, using provided for single ones and
+// providers for with and without.
+func (rd *RichDep) eval(provided func(Dependency) bool,
+	providers func(Dependency) []*Pkg) bool {
+	switch rd.Op {
+	case RichDepend:
+		return provided(rd.Dep)
+	case RichAnd:
+		for _, arg := range rd.Args {
+			if !arg.eval(provided, providers) {
+				return false
+			}
+		}
+		return true
+	case RichOr:
+		for _, arg := range rd.Args {
+			if arg.eval(provided, providers) {
+				return true
+			}
+		}
+		return false
+	case RichIf, RichUnless:
+		cond := rd.Args[1].eval(provided, providers)
+		if rd.Op == RichUnless {
+			cond = !cond
+		}
+		if cond {
+			return rd.Args[0].eval(provided, providers)
+		}
+		if len(rd.Args) == 3 {
+			return rd.Args[2].eval(provided, providers)
+		}
+		return true
+	case RichWith, RichWithout:
+		return len(rd.pkgs(providers)) > 0
+	}
+	return false
+}
+
+// pkgs: The packages that satisfy the rich dependency on their own, used
+// for with and without.
+func (rd *RichDep) pkgs(providers func(Dependency) []*Pkg) map[*Pkg]bool {
+	ret := make(map[*Pkg]bool)
+	switch rd.Op {
+	case RichDepend:
+		for _, p := range providers(rd.Dep) {
+			ret[p] = true
+		}
+	case RichOr:
+		for _, arg := range rd.Args {
+			for p := range arg.pkgs(providers) {
+				ret[p] = true
+			}
+		}
+	case RichAnd, RichWith, RichWithout:
+		ret = rd.Args[0].pkgs(providers)
+		for _, arg := range rd.Args[1:] {
+			aps := arg.pkgs(providers)
+			for p := range ret {
+				if aps[p] == (rd.Op == RichWithout) {
+					delete(ret, p)
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// Satisfied: Is the rich dependency true for the packages, an if or unless
+// condition is true when any of the packages provide it.
+func (rd *RichDep) Satisfied(pkgs *Pkgs) bool {
+	return rd.eval(pkgs.provided, func(dep Dependency) []*Pkg {
+		return pkgs.WhatProvidesDep(dep).Pkgs
+	})
+}
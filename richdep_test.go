@@ -0,0 +1,89 @@
+package repos
+
+import (
+	"testing"
+)
+
+func TestParseRichDep(t *testing.T) {
+	data := []struct {
+		s   string
+		str string
+	}{
+		{"(foo)", "foo"},
+		{"(foo if bar)", "(foo if bar)"},
+		{"(foo and bar and baz)", "(foo and bar and baz)"},
+		{"(a or (b and c))", "(a or (b and c))"},
+		{"(foo >= 1.2 if bar < 2:3-4)", "(foo >= 1.2 if bar < 2:3-4)"},
+		{"(foo if bar else baz)", "(foo if bar else baz)"},
+		{"(foo unless bar else baz)", "(foo unless bar else baz)"},
+		{"(libfoo.so.1()(64bit) with foo(x86-64))",
+			"(libfoo.so.1()(64bit) with foo(x86-64))"},
+		{"  ((a or b) without c)  ", "((a or b) without c)"},
+	}
+
+	for i := range data {
+		rd, err := ParseRichDep(data[i].s)
+		if err != nil {
+			t.Errorf("ParseRichDep(%s): %v\n", data[i].s, err)
+			continue
+		}
+		if rd.String() != data[i].str {
+			t.Errorf("ParseRichDep(%s)\n  res = %s\n  ret = %s\n",
+				data[i].s, data[i].str, rd)
+		}
+	}
+
+	for _, s := range []string{"", "foo", "(", "()", "(foo", "(foo bar)",
+		"(foo and bar or baz)", "(foo if bar else baz else quux)",
+		"(foo and)", "(foo >= )", "(foo) bar", "(and foo)"} {
+		if rd, err := ParseRichDep(s); err == nil {
+			t.Errorf("ParseRichDep(%s) didn't fail: %s\n", s, rd)
+		}
+	}
+}
+
+func TestRichDepSatisfied(t *testing.T) {
+	prov := func(name, evr string) Dependency {
+		e, _ := ParseEVR(evr)
+		return Dependency{Name: name, Flags: DepEQ, EVR: e}
+	}
+	pkgs := &Pkgs{Pkgs: []*Pkg{
+		{name: "a", provides: []Dependency{prov("a", "1.0-1"), {Name: "x"}}},
+		{name: "b", provides: []Dependency{prov("b", "2.0-1"), {Name: "y"}}},
+		{name: "c", provides: []Dependency{prov("c", "3.0-1"), {Name: "x"},
+			{Name: "y"}}},
+	}}
+
+	data := []struct {
+		s   string
+		res bool
+	}{
+		{"(a and b)", true},
+		{"(a and z)", false},
+		{"(z or b >= 2)", true},
+		{"(z or b > 2.0-1)", false},
+		{"(z if a)", false},
+		{"(z if q)", true},
+		{"(z if q else a)", true},
+		{"(z unless a)", true},
+		{"(z unless q)", false},
+		{"(z unless q else a)", false},
+		{"(x with y)", true},
+		{"(a with y)", false},
+		{"(x without y)", true},
+		{"(y without x)", true},
+		{"(c without x)", false},
+		{"((a or z) and (b if c))", true},
+	}
+	for i := range data {
+		rd, err := ParseRichDep(data[i].s)
+		if err != nil {
+			t.Errorf("ParseRichDep(%s): %v\n", data[i].s, err)
+			continue
+		}
+		if ret := rd.Satisfied(pkgs); ret != data[i].res {
+			t.Errorf("Satisfied(%s)\n  res = %v\n  ret = %v\n",
+				data[i].s, data[i].res, ret)
+		}
+	}
+}
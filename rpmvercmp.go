@@ -179,6 +179,8 @@ func rpmvercmpBytes(s1, s2 []byte) int {
 	return 0
 }
 
-func rpmvercmp(s1, s2 string) int {
-	return rpmvercmpBytes([]byte(s1), []byte(s2))
+// Vercmp: Compare two versions, or releases, the same way rpm does.
+// Returns <0, 0 or >0.
+func Vercmp(a, b string) int {
+	return rpmvercmpBytes([]byte(a), []byte(b))
 }
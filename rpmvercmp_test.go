@@ -43,9 +43,9 @@ func TestNextSlice(t *testing.T) {
 // All of the tests that start with "TestRpmC"" are from:
 // tests/rpmvercmp.at from https://github.com/rpm-software-management/rpm/
 func tRPMVERCMP(t *testing.T, a, b string, res int) {
-	ret := rpmvercmp(a, b)
+	ret := Vercmp(a, b)
 	if res != ret {
-		t.Errorf("rpmvercmp(%s, %s)\n  res = %d\n  ret = %d\n",
+		t.Errorf("Vercmp(%s, %s)\n  res = %d\n  ret = %d\n",
 			a, b, res, ret)
 	}
 }
@@ -0,0 +1,388 @@
+package repos
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SolveError: Why Solve couldn't find a set of packages to install.
+type SolveError struct {
+	Spec     string     // The spec that needed it
+	Chain    []*Pkg     // The packages that needed it, spec first
+	Dep      Dependency // The unresolved require, empty for a Spec
+	Rejected []string   // Why each of the possible packages wasn't used
+}
+
+func (e *SolveError) Error() string {
+	var msg string
+	if len(e.Chain) == 0 {
+		msg = fmt.Sprintf("no package to install for %q", e.Spec)
+	} else {
+		msg = fmt.Sprintf("nothing provides %s needed by %s", e.Dep,
+			e.Chain[len(e.Chain)-1])
+		for i := len(e.Chain) - 2; i >= 0; i-- {
+			msg += fmt.Sprintf(", needed by %s", e.Chain[i])
+		}
+		msg += fmt.Sprintf(", for %q", e.Spec)
+	}
+
+	if len(e.Rejected) > 0 {
+		msg += ": " + strings.Join(e.Rejected, "; ")
+	}
+	return msg
+}
+
+type solver struct {
+	pkgs   *Pkgs
+	prov   map[string][]depEntry // The provides index Solve started with
+	arches map[string]int
+
+	inst      map[*Pkg]bool
+	installed []*Pkg
+	byNA      map[string]*Pkg
+	byName    map[string][]*Pkg
+	conflicts map[string][]depEntry
+	obsoletes map[string][]depEntry
+	obsIdx    map[string][]depEntry // All the obsoletes, not just installed
+
+	why  map[*Pkg]*Pkg
+	spec map[*Pkg]string
+}
+
+// satisfied: Does an installed package provide the dep.
+func (s *solver) satisfied(dep Dependency) bool {
+	for _, de := range s.prov[dep.Name] {
+		if s.inst[de.pkg] && de.dep.overlaps(dep) {
+			return true
+		}
+	}
+	return false
+}
+
+// pkgDep: A dependency only matching the package, for obsoletes.
+func pkgDep(pkg *Pkg) Dependency {
+	return Dependency{Name: pkg.name, Flags: DepEQ, EVR: pkg.EVR()}
+}
+
+// rejected: Why the package can't be installed with the current ones, or ""
+func (s *solver) rejected(pkg *Pkg) string {
+	if _, ok := s.arches[pkg.arch]; !ok {
+		return fmt.Sprintf("%s is the wrong arch", pkg)
+	}
+	if o := s.byNA[pkg.Na()]; o != nil {
+		return fmt.Sprintf("%s is installed instead of %s", o, pkg)
+	}
+
+	for _, dep := range pkg.conflicts {
+		for _, de := range s.prov[dep.Name] {
+			if s.inst[de.pkg] && de.dep.overlaps(dep) {
+				return fmt.Sprintf("%s conflicts with %s", pkg, de.pkg)
+			}
+		}
+	}
+	for _, dep := range pkg.provides {
+		for _, de := range s.conflicts[dep.Name] {
+			if de.dep.overlaps(dep) {
+				return fmt.Sprintf("%s conflicts with %s", de.pkg, pkg)
+			}
+		}
+	}
+
+	for _, dep := range pkg.obsoletes {
+		for _, o := range s.byName[dep.Name] {
+			if pkgDep(o).overlaps(dep) {
+				return fmt.Sprintf("%s obsoletes %s", pkg, o)
+			}
+		}
+	}
+	for _, de := range s.obsoletes[pkg.name] {
+		if pkgDep(pkg).overlaps(de.dep) {
+			return fmt.Sprintf("%s is obsoleted by %s", pkg, de.pkg)
+		}
+	}
+
+	return ""
+}
+
+// pkgObsoletes: Does a obsolete b.
+func pkgObsoletes(a, b *Pkg) bool {
+	for _, dep := range a.obsoletes {
+		if dep.Name == b.name && pkgDep(b).overlaps(dep) {
+			return true
+		}
+	}
+	return false
+}
+
+// obsoleters: The packages that obsolete pkg.
+func (s *solver) obsoleters(pkg *Pkg) []*Pkg {
+	var ret []*Pkg
+	for _, de := range s.obsIdx[pkg.name] {
+		if de.pkg != pkg && pkgDep(pkg).overlaps(de.dep) {
+			ret = append(ret, de.pkg)
+		}
+	}
+	return ret
+}
+
+// better: Should a be installed instead of b, for the dep name. Like dnf, a
+// package that obsoletes another is always better.
+func (s *solver) better(name string, a, b *Pkg) bool {
+	if ao, bo := pkgObsoletes(a, b), pkgObsoletes(b, a); ao != bo {
+		return ao
+	}
+	if (a.name == name) != (b.name == name) {
+		return a.name == name
+	}
+	if a.name != b.name {
+		return a.name < b.name
+	}
+	if ra, rb := s.arches[a.arch], s.arches[b.arch]; ra != rb {
+		return ra < rb
+	}
+	return a.Cmp(b) > 0
+}
+
+// best: The best of the pkgs that can be installed, or the reasons none can.
+func (s *solver) best(name string, pkgs []*Pkg) (*Pkg, []string) {
+	var ret *Pkg
+	var reasons []string
+	for _, p := range pkgs {
+		if r := s.rejected(p); r != "" {
+			reasons = append(reasons, r)
+			continue
+		}
+		if ret == nil || s.better(name, p, ret) {
+			ret = p
+		}
+	}
+
+	if ret != nil {
+		return ret, nil
+	}
+	return nil, reasons
+}
+
+func (s *solver) install(pkg *Pkg, why *Pkg, spec string) {
+	s.inst[pkg] = true
+	s.installed = append(s.installed, pkg)
+	s.byNA[pkg.Na()] = pkg
+	s.byName[pkg.name] = append(s.byName[pkg.name], pkg)
+	for _, dep := range pkg.conflicts {
+		s.conflicts[dep.Name] = append(s.conflicts[dep.Name],
+			depEntry{pkg: pkg, dep: dep})
+	}
+	for _, dep := range pkg.obsoletes {
+		s.obsoletes[dep.Name] = append(s.obsoletes[dep.Name],
+			depEntry{pkg: pkg, dep: dep})
+	}
+	s.why[pkg] = why
+	s.spec[pkg] = spec
+}
+
+func (s *solver) chain(pkg *Pkg) []*Pkg {
+	var ret []*Pkg
+	for ; pkg != nil; pkg = s.why[pkg] {
+		ret = append([]*Pkg{pkg}, ret...)
+	}
+	return ret
+}
+
+func (s *solver) providers(dep Dependency) []*Pkg {
+	var ret []*Pkg
+	for _, de := range s.prov[dep.Name] {
+		if de.dep.overlaps(dep) {
+			ret = append(ret, de.pkg)
+		}
+	}
+	return ret
+}
+
+func (s *solver) installedProviders(dep Dependency) []*Pkg {
+	var ret []*Pkg
+	for _, p := range s.providers(dep) {
+		if s.inst[p] {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+func (s *solver) richSatisfied(rd *RichDep) bool {
+	return rd.eval(s.satisfied, s.installedProviders)
+}
+
+// install the best of the pkgs for pkg, which requires dep.
+func (s *solver) installBest(pkg *Pkg, dep Dependency, name string,
+	pkgs []*Pkg) error {
+	p, reasons := s.best(name, pkgs)
+	if p == nil {
+		return &SolveError{Spec: s.spec[pkg], Chain: s.chain(pkg),
+			Dep: dep, Rejected: reasons}
+	}
+	s.install(p, pkg, s.spec[pkg])
+	return nil
+}
+
+// requireRich: Install what's needed to make the rich dep true, for an or
+// the first operand that can be is used.
+func (s *solver) requireRich(pkg *Pkg, dep Dependency, rd *RichDep) error {
+	if s.richSatisfied(rd) {
+		return nil
+	}
+
+	switch rd.Op {
+	case RichDepend:
+		return s.installBest(pkg, dep, rd.Dep.Name, s.providers(rd.Dep))
+	case RichAnd:
+		for _, arg := range rd.Args {
+			if err := s.requireRich(pkg, dep, arg); err != nil {
+				return err
+			}
+		}
+	case RichOr:
+		var err error
+		for _, arg := range rd.Args {
+			if err = s.requireRich(pkg, dep, arg); err == nil {
+				return nil
+			}
+		}
+		return err
+	case RichIf, RichUnless:
+		cond := s.richSatisfied(rd.Args[1])
+		if rd.Op == RichUnless {
+			cond = !cond
+		}
+		if cond {
+			return s.requireRich(pkg, dep, rd.Args[0])
+		}
+		if len(rd.Args) == 3 {
+			return s.requireRich(pkg, dep, rd.Args[2])
+		}
+	case RichWith, RichWithout:
+		var pkgs []*Pkg
+		for p := range rd.pkgs(s.providers) {
+			pkgs = append(pkgs, p)
+		}
+		sort.Sort(ByPkg(pkgs))
+		var name string
+		if leaves := rd.leaves(); len(leaves) > 0 {
+			name = leaves[0].Name
+		}
+		return s.installBest(pkg, dep, name, pkgs)
+	}
+
+	return nil
+}
+
+// require: Install what's needed for the dep of pkg, if anything.
+func (s *solver) require(pkg *Pkg, dep Dependency) error {
+	if strings.HasPrefix(dep.Name, "rpmlib(") {
+		return nil
+	}
+
+	if dep.IsRich() {
+		rd, err := ParseRichDep(dep.Name)
+		if err != nil {
+			return &SolveError{Spec: s.spec[pkg], Chain: s.chain(pkg),
+				Dep: dep, Rejected: []string{err.Error()}}
+		}
+		return s.requireRich(pkg, dep, rd)
+	}
+
+	if s.satisfied(dep) {
+		return nil
+	}
+	return s.installBest(pkg, dep, dep.Name, s.providers(dep))
+}
+
+// Solve: Find the packages to install, on the arch, for the specs (matched
+// like Pkg.Match) and everything they require. For each name a spec matches
+// the best arch, then the newest version, is picked. Requires are provided
+// by what's already picked, if possible, else the best provider that doesn't
+// conflict with, obsolete or isn't obsoleted by anything picked. Providers,
+// and packages matching a spec, that are obsoleted lose to what obsoletes
+// them, as long as that can be installed. Picks are
+// never undone, so this can fail where a full solver like dnf wouldn't.
+// Rich requires are made true by installing the first of the options that
+// can be. Weak deps aren't installed.
+func (pkgs *Pkgs) Solve(specs []string, arch string) (*Pkgs, error) {
+	s := &solver{pkgs: pkgs, prov: pkgs.index().prov, arches: archRanks(arch),
+		inst: make(map[*Pkg]bool), byNA: make(map[string]*Pkg),
+		byName:    make(map[string][]*Pkg),
+		conflicts: make(map[string][]depEntry),
+		obsoletes: make(map[string][]depEntry),
+		obsIdx:    make(map[string][]depEntry),
+		why:       make(map[*Pkg]*Pkg), spec: make(map[*Pkg]string)}
+	for _, p := range pkgs.Pkgs {
+		for _, dep := range p.obsoletes {
+			s.obsIdx[dep.Name] = append(s.obsIdx[dep.Name],
+				depEntry{pkg: p, dep: dep})
+		}
+	}
+
+	for _, spec := range specs {
+		names := make(map[string][]*Pkg)
+		var order []string
+		for _, p := range pkgs.Match(spec).Pkgs {
+			if _, ok := s.arches[p.arch]; !ok {
+				continue
+			}
+			if _, ok := names[p.name]; !ok {
+				order = append(order, p.name)
+			}
+			names[p.name] = append(names[p.name], p)
+		}
+		if len(order) == 0 {
+			return nil, &SolveError{Spec: spec}
+		}
+
+		for _, name := range order {
+			if len(s.byName[name]) > 0 {
+				continue
+			}
+			// Anything obsoleting what the spec matched is installed
+			// instead, if it can be.
+			cands := names[name]
+			for _, p := range names[name] {
+				cands = append(cands, s.obsoleters(p)...)
+			}
+			p, reasons := s.best(name, cands)
+			if p == nil {
+				return nil, &SolveError{Spec: spec, Rejected: reasons}
+			}
+			s.install(p, nil, spec)
+		}
+	}
+
+	// Rich requires can become true, or false, as more gets installed, so
+	// check them all again until nothing more is needed.
+	for done := 0; done < len(s.installed); {
+		for ; done < len(s.installed); done++ {
+			pkg := s.installed[done]
+			for _, dep := range pkg.requires {
+				if err := s.require(pkg, dep); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		for i := 0; i < done; i++ {
+			pkg := s.installed[i]
+			for _, dep := range pkg.requires {
+				if !dep.IsRich() {
+					continue
+				}
+				if err := s.require(pkg, dep); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	ret := &Pkgs{Repo: pkgs.Repo, Pkgs: s.installed}
+	sort.Sort(ByPkg(ret.Pkgs))
+
+	return ret, nil
+}
@@ -0,0 +1,140 @@
+package repos
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// tPKG: A package from the NEVRA, which provides itself, with deps for
+// provides, requires, conflicts and obsoletes in that order.
+func tPKG(t *testing.T, nevra string, deps ...[]string) *Pkg {
+	n, err := ParseNEVRA(nevra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Pkg{name: n.Name, epoch: n.Epoch, version: n.Version,
+		release: n.Release, arch: n.Arch}
+	p.provides = append(p.provides, pkgDep(p))
+
+	lists := []*[]Dependency{&p.provides, &p.requires, &p.conflicts,
+		&p.obsoletes}
+	for i, ds := range deps {
+		for _, d := range ds {
+			dep, err := ParseDependency(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			*lists[i] = append(*lists[i], dep)
+		}
+	}
+	return p
+}
+
+func tSOLVE(t *testing.T, pkgs []*Pkg, specs []string, res []string) {
+	t.Helper()
+	ret, err := (&Pkgs{Pkgs: pkgs}).Solve(specs, "x86_64")
+	if err != nil {
+		t.Errorf("Solve(%v): %v\n", specs, err)
+		return
+	}
+	var got []string
+	for _, p := range ret.Pkgs {
+		got = append(got, p.Nvra())
+	}
+	if strings.Join(got, " ") != strings.Join(res, " ") {
+		t.Errorf("Solve(%v)\n  res = %v\n  ret = %v\n", specs, res, got)
+	}
+}
+
+func TestSolveBest(t *testing.T) {
+	pkgs := []*Pkg{
+		tPKG(t, "foo-1-1.x86_64"),
+		tPKG(t, "foo-2-1.i686"),
+		tPKG(t, "foo-2-1.x86_64"),
+		tPKG(t, "foo-3-1.s390x"),
+		tPKG(t, "bar-1-1.i686"),
+		tPKG(t, "bar-1-1.noarch"),
+	}
+	tSOLVE(t, pkgs, []string{"foo"}, []string{"foo-2-1.x86_64"})
+	tSOLVE(t, pkgs, []string{"bar"}, []string{"bar-1-1.noarch"})
+	tSOLVE(t, pkgs, []string{"foo.i686"}, []string{"foo-2-1.i686"})
+}
+
+func TestSolveConflicts(t *testing.T) {
+	pkgs := []*Pkg{
+		tPKG(t, "base-1-1.x86_64"),
+		tPKG(t, "app-1-1.x86_64", nil, []string{"lib"}),
+		tPKG(t, "lib-a-1-1.x86_64", []string{"lib"}, nil, []string{"base"}),
+		tPKG(t, "lib-b-1-1.x86_64", []string{"lib"}),
+	}
+	tSOLVE(t, pkgs, []string{"app"}, []string{"app-1-1.x86_64",
+		"lib-a-1-1.x86_64"})
+	tSOLVE(t, pkgs, []string{"base", "app"}, []string{"app-1-1.x86_64",
+		"base-1-1.x86_64", "lib-b-1-1.x86_64"})
+
+	_, err := (&Pkgs{Pkgs: pkgs[:3]}).Solve([]string{"base", "app"}, "x86_64")
+	var serr *SolveError
+	if !errors.As(err, &serr) || len(serr.Rejected) != 1 ||
+		serr.Rejected[0] != "lib-a-1-1.x86_64 conflicts with base-1-1.x86_64" {
+		t.Errorf("conflict: got %v\n", err)
+	}
+}
+
+func TestSolveObsoletes(t *testing.T) {
+	pkgs := []*Pkg{
+		tPKG(t, "app-1-1.x86_64", nil, []string{"foo"}),
+		tPKG(t, "foo-1-1.x86_64"),
+		tPKG(t, "newfoo-2-1.x86_64", []string{"foo = 2-1"}, nil, nil,
+			[]string{"foo < 2"}),
+	}
+	tSOLVE(t, pkgs, []string{"app"}, []string{"app-1-1.x86_64",
+		"newfoo-2-1.x86_64"})
+	tSOLVE(t, pkgs, []string{"foo"}, []string{"newfoo-2-1.x86_64"})
+
+	// Obsoleted, but what obsoletes it can't be installed.
+	pkgs[2].arch = "s390x"
+	tSOLVE(t, pkgs, []string{"app"}, []string{"app-1-1.x86_64",
+		"foo-1-1.x86_64"})
+}
+
+func TestSolveRich(t *testing.T) {
+	pkgs := []*Pkg{
+		tPKG(t, "app-1-1.x86_64", nil, []string{"(libfoo >= 2 or tool)",
+			"(lang if gui)"}),
+		tPKG(t, "libfoo-1-1.x86_64"),
+		tPKG(t, "tool-a-1-1.noarch", []string{"tool"}, []string{"gui"}),
+		tPKG(t, "gui-1-1.noarch"),
+		tPKG(t, "lang-1-1.noarch"),
+	}
+	tSOLVE(t, pkgs, []string{"app"}, []string{"app-1-1.x86_64",
+		"gui-1-1.noarch", "lang-1-1.noarch", "tool-a-1-1.noarch"})
+}
+
+func TestSolveError(t *testing.T) {
+	pkgs := []*Pkg{
+		tPKG(t, "top-1-1.x86_64", nil, []string{"mid"}),
+		tPKG(t, "mid-1-1.x86_64", nil, []string{"missing >= 2"}),
+		tPKG(t, "missing-1-1.x86_64"),
+	}
+	_, err := (&Pkgs{Pkgs: pkgs}).Solve([]string{"top"}, "x86_64")
+	var serr *SolveError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Solve: got %v, want a SolveError\n", err)
+	}
+	if serr.Spec != "top" || len(serr.Chain) != 2 ||
+		serr.Chain[0] != pkgs[0] || serr.Chain[1] != pkgs[1] ||
+		serr.Dep.String() != "missing >= 2" {
+		t.Errorf("SolveError: got %+v\n", serr)
+	}
+	msg := `nothing provides missing >= 2 needed by mid-1-1.x86_64, ` +
+		`needed by top-1-1.x86_64, for "top"`
+	if err.Error() != msg {
+		t.Errorf("Error()\n  res = %s\n  ret = %s\n", msg, err)
+	}
+
+	_, err = (&Pkgs{Pkgs: pkgs}).Solve([]string{"nothing"}, "x86_64")
+	if err == nil || err.Error() != `no package to install for "nothing"` {
+		t.Errorf("no match: got %v\n", err)
+	}
+}
@@ -0,0 +1,225 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Advisory: An update from updateinfo.xml, Eg. FEDORA-2018-1a2b3c4d5e
+type Advisory struct {
+	ID          string
+	Type        string // "security", "bugfix", "enhancement" or "newpackage"
+	Severity    string // Eg. "Important", or "" if there isn't one
+	Title       string
+	Description string
+	Issued      time.Time // Zero if it isn't known
+	Updated     time.Time // Zero if it never has been, or isn't known
+	References  []AdvisoryRef
+	Collections []AdvisoryCollection
+}
+
+// AdvisoryRef: A reference from an Advisory, Eg. a CVE or a bugzilla.
+type AdvisoryRef struct {
+	Type  string // Eg. "cve", "bugzilla" or "self"
+	ID    string
+	URL   string
+	Title string
+}
+
+// AdvisoryCollection: The packages of an Advisory, Eg. for a module stream.
+type AdvisoryCollection struct {
+	Short string
+	Name  string
+	Pkgs  []NEVRA
+}
+
+type xmlAdvisory struct {
+	Type     string `xml:"type,attr"`
+	ID       string `xml:"id"`
+	Title    string `xml:"title"`
+	Severity string `xml:"severity"`
+	Desc     string `xml:"description"`
+	Issued   struct {
+		Date string `xml:"date,attr"`
+	} `xml:"issued"`
+	Updated struct {
+		Date string `xml:"date,attr"`
+	} `xml:"updated"`
+	Refs []struct {
+		Type  string `xml:"type,attr"`
+		ID    string `xml:"id,attr"`
+		URL   string `xml:"href,attr"`
+		Title string `xml:"title,attr"`
+	} `xml:"references>reference"`
+	Colls []struct {
+		Short string `xml:"short,attr"`
+		Name  string `xml:"name"`
+		Pkgs  []struct {
+			Name    string `xml:"name,attr"`
+			Epoch   string `xml:"epoch,attr"`
+			Version string `xml:"version,attr"`
+			Release string `xml:"release,attr"`
+			Arch    string `xml:"arch,attr"`
+		} `xml:"package"`
+	} `xml:"pkglist>collection"`
+}
+
+// parseUpdateDate: updateinfo.xml dates are "2006-01-02 15:04:05", just the
+// day, or sometimes seconds since the epoch. Anything else is the zero time,
+// like libsolv, so one odd advisory doesn't lose all the others.
+func parseUpdateDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC()
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if tm, err := time.Parse(layout, s); err == nil {
+			return tm
+		}
+	}
+	return time.Time{}
+}
+
+func (xa *xmlAdvisory) advisory() (*Advisory, error) {
+	ret := &Advisory{ID: strings.TrimSpace(xa.ID), Type: xa.Type,
+		Severity: strings.TrimSpace(xa.Severity),
+		Title:    strings.TrimSpace(xa.Title), Description: xa.Desc}
+
+	ret.Issued = parseUpdateDate(xa.Issued.Date)
+	ret.Updated = parseUpdateDate(xa.Updated.Date)
+
+	for _, xr := range xa.Refs {
+		ret.References = append(ret.References, AdvisoryRef{Type: xr.Type,
+			ID: xr.ID, URL: xr.URL, Title: xr.Title})
+	}
+
+	for _, xc := range xa.Colls {
+		coll := AdvisoryCollection{Short: xc.Short,
+			Name: strings.TrimSpace(xc.Name)}
+		for _, xp := range xc.Pkgs {
+			n := NEVRA{Name: xp.Name, Version: xp.Version,
+				Release: xp.Release, Arch: xp.Arch, HasEpoch: true}
+			if xp.Epoch != "" {
+				var err error
+				if n.Epoch, err = strconv.Atoi(xp.Epoch); err != nil {
+					return nil, fmt.Errorf("bad epoch in %s: %s", ret.ID,
+						xp.Epoch)
+				}
+			}
+			coll.Pkgs = append(coll.Pkgs, n)
+		}
+		ret.Collections = append(ret.Collections, coll)
+	}
+
+	return ret, nil
+}
+
+// parseUpdateinfo: Calls fn with each of the advisories.
+func parseUpdateinfo(r io.Reader, fn func(*Advisory) error) error {
+	var xa xmlAdvisory
+	return xmlEach(r, "update", &xa, func() error {
+		adv, err := xa.advisory()
+		xa = xmlAdvisory{}
+		if err != nil {
+			return err
+		}
+		return fn(adv)
+	})
+}
+
+// Advisories: Sorted by ID, see Repodata.LoadUpdateinfo
+type Advisories []*Advisory
+
+func (repo *Repodata) LoadUpdateinfo() (Advisories, error) {
+	return repo.LoadUpdateinfoContext(context.Background())
+}
+
+// LoadUpdateinfoContext: Download updateinfo.xml and parse the advisories.
+func (repo *Repodata) LoadUpdateinfoContext(ctx context.Context) (Advisories,
+	error) {
+	if repo.Updateinfo.Path == "" {
+		return nil, errors.New("no updateinfo in the repo")
+	}
+
+	var ret Advisories
+	err := repo.fetchStream(ctx, repo.Updateinfo, func(r io.Reader) error {
+		ret = nil
+		return parseUpdateinfo(r, func(adv *Advisory) error {
+			ret = append(ret, adv)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+
+	return ret, nil
+}
+
+// Updates: The installed packages the advisory has a newer version of, for
+// the same name.arch.
+func (adv *Advisory) Updates(installed *Pkgs) []*Pkg {
+	var ret []*Pkg
+	for _, p := range installed.Pkgs {
+		for _, coll := range adv.Collections {
+			found := false
+			for _, n := range coll.Pkgs {
+				if n.Name == p.name && n.Arch == p.arch &&
+					n.EVR().Compare(p.EVR()) > 0 {
+					found = true
+					break
+				}
+			}
+			if found {
+				ret = append(ret, p)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// Affecting: The advisories that update any of the installed packages, like
+// dnf updateinfo list.
+func (advs Advisories) Affecting(installed *Pkgs) Advisories {
+	var ret Advisories
+	for _, adv := range advs {
+		if len(adv.Updates(installed)) > 0 {
+			ret = append(ret, adv)
+		}
+	}
+	return ret
+}
+
+// OfType: The advisories with any of the types, Eg. "security".
+func (advs Advisories) OfType(types ...string) Advisories {
+	var ret Advisories
+	for _, adv := range advs {
+		for _, t := range types {
+			if adv.Type == t {
+				ret = append(ret, adv)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// Since: The advisories issued at or after the time.
+func (advs Advisories) Since(tm time.Time) Advisories {
+	var ret Advisories
+	for _, adv := range advs {
+		if !adv.Issued.Before(tm) {
+			ret = append(ret, adv)
+		}
+	}
+	return ret
+}
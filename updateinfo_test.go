@@ -0,0 +1,103 @@
+package repos
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const tUPDATEINFO = `<?xml version="1.0" encoding="UTF-8"?>
+<updates>
+  <update from="updates@fedoraproject.org" status="stable" type="security" version="2.0">
+    <id>FEDORA-2018-0002</id>
+    <title>foo-1.1-1</title>
+    <issued date="2018-06-01 12:00:00"/>
+    <updated date="2018-06-02 12:00:00"/>
+    <severity>Important</severity>
+    <description>Fixes CVE-2018-0001</description>
+    <references>
+      <reference href="https://bugzilla.redhat.com/1" id="1" title="CVE-2018-0001 foo: bad" type="bugzilla"/>
+      <reference href="https://cve.mitre.org/CVE-2018-0001" id="CVE-2018-0001" type="cve"/>
+    </references>
+    <pkglist>
+      <collection short="F28">
+        <name>Fedora 28</name>
+        <package name="foo" version="1.1" release="1" epoch="0" arch="noarch" src="foo-1.1-1.src.rpm">
+          <filename>foo-1.1-1.noarch.rpm</filename>
+        </package>
+      </collection>
+    </pkglist>
+  </update>
+  <update from="updates@fedoraproject.org" status="stable" type="bugfix" version="2.0">
+    <id>FEDORA-2018-0001</id>
+    <title>bar-2.0-1</title>
+    <issued date="1514764800"/>
+    <pkglist>
+      <collection short="F28">
+        <name>Fedora 28</name>
+        <package name="bar" version="2.0" release="1" epoch="1" arch="x86_64">
+          <filename>bar-2.0-1.x86_64.rpm</filename>
+        </package>
+      </collection>
+    </pkglist>
+  </update>
+</updates>
+`
+
+func TestLoadUpdateinfo(t *testing.T) {
+	dir := tREPODATA(t, map[string]string{"updateinfo": tUPDATEINFO})
+	defer os.RemoveAll(dir)
+
+	pkgs := tLOAD(t, dir)
+	advs, err := pkgs.Repo.LoadUpdateinfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(advs) != 2 || advs[0].ID != "FEDORA-2018-0001" {
+		t.Fatalf("LoadUpdateinfo: got %v\n", advs)
+	}
+
+	sec := advs[1]
+	if sec.Type != "security" || sec.Severity != "Important" ||
+		!sec.Issued.Equal(time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)) ||
+		len(sec.References) != 2 || sec.References[1].ID != "CVE-2018-0001" ||
+		len(sec.Collections) != 1 ||
+		sec.Collections[0].Pkgs[0].String() != "foo-1.1-1.noarch" {
+		t.Errorf("advisory: got %+v\n", sec)
+	}
+	if !advs[0].Issued.Equal(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("epoch date: got %v\n", advs[0].Issued)
+	}
+
+	// The repo has foo-1.0-1.noarch and bar-1:2.0-1.x86_64 "installed".
+	aff := advs.Affecting(pkgs)
+	if len(aff) != 1 || aff[0] != sec {
+		t.Errorf("Affecting: got %v\n", aff)
+	}
+
+	since := advs.OfType("security").Since(time.Date(2018, 5, 1, 0, 0, 0, 0,
+		time.UTC))
+	if len(since) != 1 || since[0] != sec {
+		t.Errorf("Since: got %v\n", since)
+	}
+	if n := len(advs.Since(time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC))); n != 0 {
+		t.Errorf("Since July: got %d\n", n)
+	}
+}
+
+func TestUpdateinfoBadDate(t *testing.T) {
+	updateinfo := strings.Replace(tUPDATEINFO, `<updated date="2018-06-02 12:00:00"/>`,
+		`<updated date="2018-06-02 12:00:00 UTC"/>`, 1)
+	dir := tREPODATA(t, map[string]string{"updateinfo": updateinfo})
+	defer os.RemoveAll(dir)
+
+	advs, err := tLOAD(t, dir).Repo.LoadUpdateinfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(advs) != 2 || !advs[1].Updated.IsZero() ||
+		advs[1].Issued.IsZero() {
+		t.Errorf("LoadUpdateinfo: got %v\n", advs)
+	}
+}